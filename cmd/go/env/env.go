@@ -29,16 +29,6 @@ var CmdEnv = &cobra.Command{
     Short: "print Go environment information",
     Long: `
 Env prints Go environment information.
-
-By default env prints information as a shell script
-(on Windows, a batch file). If one or more variable
-names is given as arguments, env prints the value of
-each named variable on its own line.
-
-The -json flag prints the environment in JSON format
-instead of as a shell script.
-
-For more about environment variables, see 'go help environment'.
 `,
     DisableFlagParsing: true,
 }
@@ -46,7 +36,6 @@ For more about environment variables, see 'go help environment'.
 func init() {
     CmdEnv.Run = func(cmd *cobra.Command, args []string) {
         fd := util.GetGoBinaryCMD("env", args)
-        fd.Env = util.GetEnvWithLocalProxy()
         fd.Stdout = os.Stdout
         fd.Stderr = os.Stderr
         util.RunCMDWithExit(fd)
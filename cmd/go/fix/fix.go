@@ -29,13 +29,6 @@ var CmdFix = &cobra.Command{
     Short: "update packages to use new APIs",
     Long: `
 Fix runs the Go fix command on the packages named by the import paths.
-
-For more about fix, see 'go doc cmd/fix'.
-For more about specifying packages, see 'go help packages'.
-
-To run fix with specific options, run 'go tool fix'.
-
-See also: go fmt, go vet.
 `,
     DisableFlagParsing: true,
 }
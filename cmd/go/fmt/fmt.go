@@ -30,16 +30,6 @@ var CmdFmt = &cobra.Command{
     Long: `
 Fmt runs the command 'gofmt -l -w' on the packages named
 by the import paths. It prints the names of the files that are modified.
-
-For more about gofmt, see 'go doc cmd/gofmt'.
-For more about specifying packages, see 'go help packages'.
-
-The -n flag prints commands that would be executed.
-The -x flag prints commands as they are executed.
-
-To run gofmt with specific options, run gofmt itself.
-
-See also: go fix, go vet.
 `,
     DisableFlagParsing: true,
 }
@@ -47,7 +37,6 @@ See also: go fix, go vet.
 func init() {
     CmdFmt.Run = func(cmd *cobra.Command, args []string) {
         fd := util.GetGoBinaryCMD("fmt", args)
-        fd.Env = util.GetEnvWithLocalProxy()
         fd.Stdout = os.Stdout
         fd.Stderr = os.Stderr
         util.RunCMDWithExit(fd)
@@ -29,13 +29,6 @@ var CmdInstall = &cobra.Command{
     Short: "compile and install packages and dependencies",
     Long: `
 Install compiles and installs the packages named by the import paths.
-
-The -i flag installs the dependencies of the named packages as well.
-
-For more about the build flags, see 'go help build'.
-For more about specifying packages, see 'go help packages'.
-
-See also: go build, go get, go clean.
 `,
     DisableFlagParsing: true,
 }
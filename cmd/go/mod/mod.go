@@ -27,11 +27,6 @@ var CmdMod = &cobra.Command{
     Short: "module maintenance",
     Long: `
 Go mod provides access to operations on modules.
-
-Note that support for modules is built into all the go commands,
-not just 'go mod'. For example, day-to-day adding, removing, upgrading,
-and downgrading of dependencies should be done using 'go get'.
-See 'go help modules' for an overview of module functionality.
 `,
     DisableFlagParsing: true,
 }
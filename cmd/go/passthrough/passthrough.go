@@ -0,0 +1,165 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package passthrough
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "github.com/storyicon/gos/pkg/toolchain"
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// AnnotationGo marks the commands which wrap the go sub command of the same name
+const AnnotationGo = "gos/go"
+
+// CmdHelp prints the help of gos commands, go commands and go help topics
+var CmdHelp = &cobra.Command{
+    Use:   "help [command | topic]",
+    Short: "help about any command or topic",
+    Long: `
+Help prints the help of any gos command, go command or go help topic.
+The help of go commands and topics comes from 'go help' of your go binary,
+followed by the extensions of gos.
+
+    - Print the commands of gos and go
+    gos help
+
+    - Print the help of go build and the flags added by gos
+    gos help build
+
+    - Print the go help topic about modules
+    gos help modules
+`,
+}
+
+func init() {
+    CmdHelp.Run = RunHelp
+}
+
+// Run is used to pass the go sub command through to the go binary with the local proxy
+func Run(subcmd string, args []string) {
+    ApplyToolchain()
+    fd := util.GetGoBinaryCMD(subcmd, args)
+    fd.Env = util.GetEnvWithLocalProxy()
+    fd.Stdout = os.Stdout
+    fd.Stderr = os.Stderr
+    util.RunCMDWithExit(fd)
+}
+
+// ApplyToolchain is used to switch to the go toolchain selected by GOS_TOOLCHAIN or the pin file,
+// it is only applied by the go commands, the commands of gos use the go binary in $PATH
+func ApplyToolchain() {
+    if err := toolchain.Apply(util.GetLocalProxyURL); err != nil {
+        log.Printf("failed to use the go toolchain: %s", err)
+        os.Exit(1)
+    }
+}
+
+// Wrap is used to mark the commands as wrapped go commands, which run the selected toolchain,
+// their help is printed by `go help` followed by the extensions of gos.
+// The first paragraph of the Long is a short help of the go command, which is printed
+// with the extensions only when `go help` fails, the other paragraphs are the extensions.
+func Wrap(cmds ...*cobra.Command) []*cobra.Command {
+    for _, cmd := range cmds {
+        if cmd.Annotations == nil {
+            cmd.Annotations = map[string]string{}
+        }
+        cmd.Annotations[AnnotationGo] = cmd.Name()
+        cmd.SetHelpFunc(HelpFunc)
+        cmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+            ApplyToolchain()
+        }
+    }
+    return cmds
+}
+
+// IsWrapped is used to determine whether the command wraps a go command
+func IsWrapped(cmd *cobra.Command) bool {
+    _, ok := cmd.Annotations[AnnotationGo]
+    return ok
+}
+
+// HelpFunc prints `go help <command>` and the extensions of gos in the Long of command,
+// the Long is printed as it is when `go help` fails
+func HelpFunc(cmd *cobra.Command, args []string) {
+    if _, err := printGoHelp([]string{cmd.Annotations[AnnotationGo]}); err != nil {
+        printLong(cmd)
+        return
+    }
+    printExtensions(cmd)
+}
+
+// RunHelp is used to execute the help command
+func RunHelp(cmd *cobra.Command, args []string) {
+    root := cmd.Root()
+    if len(args) == 0 {
+        root.Help()
+        fmt.Println()
+        if code, err := printGoHelp(nil); err != nil {
+            os.Exit(code)
+        }
+        return
+    }
+    c, _, err := root.Find(args)
+    if err == nil && c != root && !IsWrapped(c) {
+        c.Help()
+        return
+    }
+    wrapped := err == nil && IsWrapped(c)
+    code, err := printGoHelp(args)
+    switch {
+    case err != nil && wrapped:
+        printLong(c)
+    case err != nil:
+        os.Exit(code)
+    case wrapped:
+        printExtensions(c)
+    }
+}
+
+// printGoHelp is used to run `go help`, the error is not nil when it fails
+func printGoHelp(args []string) (int, error) {
+    fd := util.GetGoBinaryCMD("help", args)
+    fd.Stdout = os.Stdout
+    fd.Stderr = os.Stderr
+    code, err := util.RunCMD(fd)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "gos: failed to run %s: %s\n", fd.Path, err)
+        return code, err
+    }
+    if code != 0 {
+        return code, fmt.Errorf("go help exited with code %d", code)
+    }
+    return 0, nil
+}
+
+// printLong is used to print the usage and the Long of the wrapped command
+func printLong(cmd *cobra.Command) {
+    fmt.Printf("usage: gos %s\n\n%s\n", cmd.Use, strings.TrimSpace(cmd.Long))
+}
+
+// printExtensions is used to print the Long of the wrapped command without its first paragraph
+func printExtensions(cmd *cobra.Command) {
+    long := strings.TrimSpace(cmd.Long)
+    i := strings.Index(long, "\n\n")
+    if i == -1 {
+        return
+    }
+    fmt.Printf("\n%s\n", strings.TrimSpace(long[i:]))
+}
@@ -29,26 +29,6 @@ var CmdRun = &cobra.Command{
     Short: "compile and run Go program",
     Long: `
 Run compiles and runs the named main Go package.
-Typically the package is specified as a list of .go source files,
-but it may also be an import path, file system path, or pattern
-matching a single known package, as in 'go run .' or 'go run my/cmd'.
-
-By default, 'go run' runs the compiled binary directly: 'a.out arguments...'.
-If the -exec flag is given, 'go run' invokes the binary using xprog:
-    'xprog a.out arguments...'.
-If the -exec flag is not given, GOOS or GOARCH is different from the system
-default, and a program named go_$GOOS_$GOARCH_exec can be found
-on the current search path, 'go run' invokes the binary using that program,
-for example 'go_nacl_386_exec a.out arguments...'. This allows execution of
-cross-compiled programs when a simulator or other execution method is
-available.
-
-The exit status of Run is not the exit status of the compiled binary.
-
-For more about build flags, see 'go help build'.
-For more about specifying packages, see 'go help packages'.
-
-See also: go build.
 `,
     DisableFlagParsing: true,
 }
@@ -0,0 +1,482 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "bufio"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "github.com/storyicon/gos/pkg/util"
+)
+
+var (
+    // ErrNoModule is returned when --cover-all is used outside a module
+    ErrNoModule = errors.New("--cover-all requires a go module")
+    // ErrNoProfile is returned when no coverage profile is written by go test
+    ErrNoProfile = errors.New("no coverage profile is written by go test")
+    // ErrInvalidProfile is returned when a line of the coverage profile can not be parsed
+    ErrInvalidProfile = errors.New("invalid coverage profile")
+)
+
+// Block is a block of statements in the coverage profile
+type Block struct {
+    File      string
+    StartLine int
+    StartCol  int
+    EndLine   int
+    EndCol    int
+    NumStmt   int
+    Count     int
+}
+
+func (b *Block) key() string {
+    return fmt.Sprintf("%s:%d.%d,%d.%d", b.File, b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+}
+
+// Profile is a coverage profile merged from the profiles of go test
+type Profile struct {
+    Mode   string
+    Blocks []*Block
+
+    blocks map[string]*Block
+}
+
+// NewProfile is used to initialize an empty profile
+func NewProfile() *Profile {
+    return &Profile{blocks: map[string]*Block{}}
+}
+
+// Merge is used to merge a profile written by go test -coverprofile,
+// the counts of the same block are added, or set in the set mode
+func (p *Profile) Merge(r io.Reader) error {
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" {
+            continue
+        }
+        if strings.HasPrefix(line, "mode:") {
+            p.Mode = strings.TrimSpace(strings.TrimPrefix(line, "mode:"))
+            continue
+        }
+        b, err := parseBlock(line)
+        if err != nil {
+            return err
+        }
+        current, ok := p.blocks[b.key()]
+        if !ok {
+            p.blocks[b.key()] = b
+            p.Blocks = append(p.Blocks, b)
+            continue
+        }
+        if p.Mode == "set" {
+            if b.Count > 0 {
+                current.Count = 1
+            }
+        } else {
+            current.Count += b.Count
+        }
+    }
+    return scanner.Err()
+}
+
+// parseBlock is used to parse a line such as example.com/m/a.go:3.20,5.2 2 1
+func parseBlock(line string) (*Block, error) {
+    i := strings.LastIndex(line, ":")
+    if i == -1 {
+        return nil, ErrInvalidProfile
+    }
+    b := &Block{File: line[:i]}
+    _, err := fmt.Sscanf(line[i+1:], "%d.%d,%d.%d %d %d", &b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol, &b.NumStmt, &b.Count)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %s", ErrInvalidProfile, line)
+    }
+    return b, nil
+}
+
+// Sort is used to sort the blocks by file and position
+func (p *Profile) Sort() {
+    sort.SliceStable(p.Blocks, func(i, j int) bool {
+        a, b := p.Blocks[i], p.Blocks[j]
+        if a.File != b.File {
+            return a.File < b.File
+        }
+        if a.StartLine != b.StartLine {
+            return a.StartLine < b.StartLine
+        }
+        return a.StartCol < b.StartCol
+    })
+}
+
+// Write is used to write the profile in the format of go test -coverprofile
+func (p *Profile) Write(w io.Writer) error {
+    if _, err := fmt.Fprintf(w, "mode: %s\n", p.Mode); err != nil {
+        return err
+    }
+    for _, b := range p.Blocks {
+        if _, err := fmt.Fprintf(w, "%s %d %d\n", b.key(), b.NumStmt, b.Count); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Coverage is the covered and total statements
+type Coverage struct {
+    Name       string
+    Covered    int
+    Statements int
+}
+
+// Percent is used to get the percentage of the covered statements
+func (c *Coverage) Percent() float64 {
+    if c.Statements == 0 {
+        return 0
+    }
+    return float64(c.Covered) * 100 / float64(c.Statements)
+}
+
+func (c *Coverage) add(b *Block) {
+    c.Statements += b.NumStmt
+    if b.Count > 0 {
+        c.Covered += b.NumStmt
+    }
+}
+
+// Files is used to get the coverage of each file, they are sorted by name
+func (p *Profile) Files() []*Coverage {
+    files := map[string]*Coverage{}
+    var r []*Coverage
+    for _, b := range p.Blocks {
+        c, ok := files[b.File]
+        if !ok {
+            c = &Coverage{Name: b.File}
+            files[b.File] = c
+            r = append(r, c)
+        }
+        c.add(b)
+    }
+    sort.Slice(r, func(i, j int) bool {
+        return r[i].Name < r[j].Name
+    })
+    return r
+}
+
+// Total is used to get the coverage of all the statements
+func (p *Profile) Total() *Coverage {
+    c := &Coverage{Name: "TOTAL"}
+    for _, b := range p.Blocks {
+        c.add(b)
+    }
+    return c
+}
+
+// WriteTable is used to write the coverage of each package followed by its files
+func (p *Profile) WriteTable(w io.Writer) error {
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "PACKAGE / FILE\tCOVERAGE\tSTATEMENTS")
+    for _, pkg := range groupFiles(p.Files()) {
+        fmt.Fprintf(tw, "%s\t%.1f%%\t%d/%d\n", pkg.Name, pkg.Percent(), pkg.Covered, pkg.Statements)
+        for _, file := range pkg.files {
+            fmt.Fprintf(tw, "    %s\t%.1f%%\t%d/%d\n", path.Base(file.Name), file.Percent(), file.Covered, file.Statements)
+        }
+    }
+    total := p.Total()
+    fmt.Fprintf(tw, "%s\t%.1f%%\t%d/%d\n", total.Name, total.Percent(), total.Covered, total.Statements)
+    return tw.Flush()
+}
+
+type packageCoverage struct {
+    Coverage
+    files []*Coverage
+}
+
+// groupFiles is used to group the sorted files by their packages
+func groupFiles(files []*Coverage) []*packageCoverage {
+    var r []*packageCoverage
+    for _, file := range files {
+        name := path.Dir(file.Name)
+        if len(r) == 0 || r[len(r)-1].Name != name {
+            r = append(r, &packageCoverage{Coverage: Coverage{Name: name}})
+        }
+        pkg := r[len(r)-1]
+        pkg.Covered += file.Covered
+        pkg.Statements += file.Statements
+        pkg.files = append(pkg.files, file)
+    }
+    return r
+}
+
+type coberturaCoverage struct {
+    XMLName         xml.Name            `xml:"coverage"`
+    LineRate        string              `xml:"line-rate,attr"`
+    BranchRate      string              `xml:"branch-rate,attr"`
+    LinesCovered    int                 `xml:"lines-covered,attr"`
+    LinesValid      int                 `xml:"lines-valid,attr"`
+    BranchesCovered int                 `xml:"branches-covered,attr"`
+    BranchesValid   int                 `xml:"branches-valid,attr"`
+    Complexity      string              `xml:"complexity,attr"`
+    Version         string              `xml:"version,attr"`
+    Timestamp       int64               `xml:"timestamp,attr"`
+    Sources         []string            `xml:"sources>source"`
+    Packages        []*coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+    Name       string            `xml:"name,attr"`
+    LineRate   string            `xml:"line-rate,attr"`
+    BranchRate string            `xml:"branch-rate,attr"`
+    Complexity string            `xml:"complexity,attr"`
+    Classes    []*coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+    Name       string          `xml:"name,attr"`
+    Filename   string          `xml:"filename,attr"`
+    LineRate   string          `xml:"line-rate,attr"`
+    BranchRate string          `xml:"branch-rate,attr"`
+    Complexity string          `xml:"complexity,attr"`
+    Methods    struct{}        `xml:"methods"`
+    Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+    Number int `xml:"number,attr"`
+    Hits   int `xml:"hits,attr"`
+}
+
+// coberturaFilename is used to get the path of the file relative to the module folder,
+// the files outside the module keep their import paths
+func coberturaFilename(name string, module string) string {
+    return strings.TrimPrefix(name, module+"/")
+}
+
+func lineRate(covered, valid int) string {
+    if valid == 0 {
+        return "0"
+    }
+    return strconv.FormatFloat(float64(covered)/float64(valid), 'f', 4, 64)
+}
+
+// WriteCobertura is used to write the line coverage in Cobertura XML format,
+// the files of the module are relative to its folder, which is the source of the report
+func (p *Profile) WriteCobertura(w io.Writer, module string, dir string) error {
+    report := &coberturaCoverage{
+        BranchRate: "0",
+        Complexity: "0",
+        Version:    "gos",
+        Timestamp:  time.Now().UnixNano() / int64(time.Millisecond),
+        Sources:    []string{dir},
+    }
+    lines := map[string]map[int]int{}
+    for _, b := range p.Blocks {
+        if lines[b.File] == nil {
+            lines[b.File] = map[int]int{}
+        }
+        for line := b.StartLine; line <= b.EndLine; line++ {
+            if hits, ok := lines[b.File][line]; !ok || b.Count > hits {
+                lines[b.File][line] = b.Count
+            }
+        }
+    }
+    for _, pkg := range groupFiles(p.Files()) {
+        cp := &coberturaPackage{Name: pkg.Name, BranchRate: "0", Complexity: "0"}
+        pkgCovered, pkgValid := 0, 0
+        for _, file := range pkg.files {
+            class := &coberturaClass{
+                Name:       path.Base(file.Name),
+                Filename:   coberturaFilename(file.Name, module),
+                BranchRate: "0",
+                Complexity: "0",
+            }
+            var numbers []int
+            for number := range lines[file.Name] {
+                numbers = append(numbers, number)
+            }
+            sort.Ints(numbers)
+            covered := 0
+            for _, number := range numbers {
+                hits := lines[file.Name][number]
+                class.Lines = append(class.Lines, coberturaLine{Number: number, Hits: hits})
+                if hits > 0 {
+                    covered++
+                }
+            }
+            class.LineRate = lineRate(covered, len(numbers))
+            pkgCovered += covered
+            pkgValid += len(numbers)
+            cp.Classes = append(cp.Classes, class)
+        }
+        cp.LineRate = lineRate(pkgCovered, pkgValid)
+        report.LinesCovered += pkgCovered
+        report.LinesValid += pkgValid
+        report.Packages = append(report.Packages, cp)
+    }
+    report.LineRate = lineRate(report.LinesCovered, report.LinesValid)
+
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+    }
+    encoder := xml.NewEncoder(w)
+    encoder.Indent("", "  ")
+    if err := encoder.Encode(report); err != nil {
+        return err
+    }
+    _, err := io.WriteString(w, "\n")
+    return err
+}
+
+// Cover collects the coverage of the go test runs across the module
+type Cover struct {
+    // Module and Dir are the path and folder of the main module
+    Module string
+    Dir    string
+    // Packages is the -coverpkg of the runs, the default is all the packages of the module
+    Packages string
+    // Output is the path of the merged profile, it is the -coverprofile of the arguments
+    Output string
+
+    tmp  string
+    runs int
+}
+
+// NewCover is used to initialize a Cover for the arguments of go test
+func NewCover(args []string) (*Cover, error) {
+    output, err := runGo("list", []string{"-m", "-f", "{{.Path}}\t{{.Dir}}"})
+    if err != nil {
+        return nil, ErrNoModule
+    }
+    fields := strings.Split(strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0], "\t")
+    if len(fields) != 2 || fields[1] == "" {
+        return nil, ErrNoModule
+    }
+    flags, _, _ := SplitArgs(args)
+    c := &Cover{
+        Module:   fields[0],
+        Dir:      fields[1],
+        Packages: flagValue(flags, "coverpkg"),
+        Output:   flagValue(flags, "coverprofile"),
+    }
+    if c.Packages == "" {
+        c.Packages = c.Module + "/..."
+    }
+    c.tmp, err = ioutil.TempDir("", "gos-cover")
+    return c, err
+}
+
+// Apply is used to make each run write its own profile with the -coverpkg
+func (c *Cover) Apply(runs [][]string) [][]string {
+    r := make([][]string, len(runs))
+    for i, run := range runs {
+        flags, packages, testArgs := SplitArgs(run)
+        flags = removeFlags(flags, "coverprofile", "coverpkg")
+        flags = append(flags, "-coverpkg="+c.Packages, "-coverprofile="+filepath.Join(c.tmp, fmt.Sprintf("%d.out", i)))
+        r[i] = append(append(flags, packages...), testArgs...)
+    }
+    c.runs = len(runs)
+    return r
+}
+
+// Merge is used to merge the profiles written by the runs
+func (c *Cover) Merge() (*Profile, error) {
+    profile := NewProfile()
+    merged := 0
+    for i := 0; i < c.runs; i++ {
+        file, err := os.Open(filepath.Join(c.tmp, fmt.Sprintf("%d.out", i)))
+        if os.IsNotExist(err) {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        err = profile.Merge(file)
+        file.Close()
+        if err != nil {
+            return nil, err
+        }
+        merged++
+    }
+    if merged == 0 {
+        return nil, ErrNoProfile
+    }
+    profile.Sort()
+    return profile, nil
+}
+
+// Report is used to merge the profiles and write the coverage reports by the options,
+// it returns the exit code, which is 1 if the tests pass but the coverage is below the threshold
+func (c *Cover) Report(options *Options, code int) int {
+    defer os.RemoveAll(c.tmp)
+    profile, err := c.Merge()
+    if err == nil {
+        err = c.write(profile, options)
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "gos: %s\n", err)
+        if code == 0 {
+            code = 1
+        }
+        return code
+    }
+    if total := profile.Total().Percent(); options.CoverThreshold > 0 && total < options.CoverThreshold {
+        fmt.Fprintf(os.Stderr, "gos: coverage %.1f%% is below the threshold %.1f%%\n", total, options.CoverThreshold)
+        if code == 0 {
+            code = 1
+        }
+    }
+    return code
+}
+
+func (c *Cover) write(profile *Profile, options *Options) error {
+    fmt.Println()
+    if err := profile.WriteTable(os.Stdout); err != nil {
+        return err
+    }
+    output := c.Output
+    if output == "" {
+        output = filepath.Join(c.tmp, "merged.out")
+    }
+    if err := writeFile(output, profile.Write); err != nil {
+        return err
+    }
+    if options.CoverHTML != "" {
+        fd := util.GetGoBinaryCMD("tool", []string{"cover", "-html=" + output, "-o", options.CoverHTML})
+        fd.Stdout = os.Stdout
+        fd.Stderr = os.Stderr
+        if err := fd.Run(); err != nil {
+            return fmt.Errorf("go tool cover: %s", err)
+        }
+    }
+    if options.CoverXML != "" {
+        return writeFile(options.CoverXML, func(w io.Writer) error {
+            return profile.WriteCobertura(w, c.Module, c.Dir)
+        })
+    }
+    return nil
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestProfile_Merge(t *testing.T) {
+    profile := NewProfile()
+    assert.NoError(t, profile.Merge(strings.NewReader("mode: set\nm/a/a.go:3.20,5.2 2 1\nm/a/a.go:7.2,7.10 1 0\n")))
+    assert.NoError(t, profile.Merge(strings.NewReader("mode: set\nm/a/a.go:7.2,7.10 1 1\nm/a/a.go:3.20,5.2 2 1\nm/b.go:1.1,2.2 3 0\n")))
+    assert.Error(t, profile.Merge(strings.NewReader("m/a/a.go:1.1 2 1\n")))
+    profile.Sort()
+
+    buf := &bytes.Buffer{}
+    assert.NoError(t, profile.Write(buf))
+    assert.Equal(t, "mode: set\nm/a/a.go:3.20,5.2 2 1\nm/a/a.go:7.2,7.10 1 1\nm/b.go:1.1,2.2 3 0\n", buf.String())
+    assert.Equal(t, 50.0, profile.Total().Percent())
+
+    files := profile.Files()
+    assert.Len(t, files, 2)
+    assert.Equal(t, &Coverage{Name: "m/a/a.go", Covered: 3, Statements: 3}, files[0])
+
+    counts := NewProfile()
+    assert.NoError(t, counts.Merge(strings.NewReader("mode: count\nm/a.go:1.1,2.2 1 2\n")))
+    assert.NoError(t, counts.Merge(strings.NewReader("mode: count\nm/a.go:1.1,2.2 1 3\n")))
+    assert.Equal(t, 5, counts.Blocks[0].Count)
+}
+
+func TestProfile_WriteCobertura(t *testing.T) {
+    profile := NewProfile()
+    assert.NoError(t, profile.Merge(strings.NewReader("mode: count\nexample.com/m/a/a.go:3.20,5.2 2 4\nexample.com/m/a/a.go:5.2,6.10 1 0\n")))
+    buf := &bytes.Buffer{}
+    assert.NoError(t, profile.WriteCobertura(buf, "example.com/m", "/src/m"))
+    output := buf.String()
+    assert.Contains(t, output, `<source>/src/m</source>`)
+    assert.Contains(t, output, `<class name="a.go" filename="a/a.go" line-rate="0.7500"`)
+    assert.Contains(t, output, `<line number="5" hits="4"></line>`)
+    assert.Contains(t, output, `<line number="6" hits="0"></line>`)
+}
+
+func TestCoberturaFilename(t *testing.T) {
+    assert.Equal(t, "a/a.go", coberturaFilename("example.com/m/a/a.go", "example.com/m"))
+    assert.Equal(t, "example.com/mx/a.go", coberturaFilename("example.com/mx/a.go", "example.com/m"))
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "github.com/storyicon/gos/pkg/concurrent"
+    "github.com/storyicon/gos/pkg/toolchain"
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// Result is the result of running the tests with a toolchain
+type Result struct {
+    Toolchain string
+    Binary    string
+    Version   string
+    Passed    bool
+    Duration  time.Duration
+    // FailedPackages is the packages reported as FAIL by go test
+    FailedPackages []string
+    Output         []byte
+    Err            error
+}
+
+// Status is used to get the status of the result in the matrix
+func (r *Result) Status() string {
+    switch {
+    case r.Passed:
+        return "ok"
+    case r.Err != nil && len(r.Output) == 0:
+        return "error"
+    }
+    return "FAIL"
+}
+
+// RunMatrix is used to run go test with the arguments under each toolchain,
+// the outputs of the failed toolchains and the pass/fail matrix are written to w
+func RunMatrix(args []string, options *Options, w io.Writer) bool {
+    results := make([]*Result, len(options.Toolchains))
+    for i, spec := range options.Toolchains {
+        results[i] = &Result{Toolchain: spec}
+        results[i].Binary, results[i].Err = toolchain.Find(spec)
+    }
+
+    env := matrixEnv(util.GetEnvWithLocalProxy())
+    c := concurrent.New(options.Parallel)
+    for _, result := range results {
+        if result.Err != nil {
+            continue
+        }
+        c.Add(1)
+        go func(result *Result) {
+            defer c.Done()
+            result.run(args, env)
+        }(result)
+    }
+    c.Wait()
+
+    passed := true
+    for _, result := range results {
+        if result.Passed {
+            continue
+        }
+        passed = false
+        if len(result.Output) != 0 {
+            fmt.Fprintf(w, "=== %s (%s)\n%s\n", result.Toolchain, result.Binary, result.Output)
+        }
+    }
+    writeMatrix(w, results)
+    return passed
+}
+
+// matrixEnv is used to make each go binary run itself with its own GOROOT
+func matrixEnv(env []string) []string {
+    var r []string
+    for _, e := range env {
+        if !strings.HasPrefix(e, "GOROOT=") && !strings.HasPrefix(e, "GOTOOLCHAIN=") {
+            r = append(r, e)
+        }
+    }
+    return append(r, "GOTOOLCHAIN=local")
+}
+
+func (r *Result) run(args []string, env []string) {
+    version, err := exec.Command(r.Binary, "env", "GOVERSION").Output()
+    if err == nil {
+        r.Version = strings.TrimSpace(string(version))
+    }
+
+    output := &bytes.Buffer{}
+    fd := exec.Command(r.Binary, append([]string{"test"}, args...)...)
+    fd.Env = env
+    fd.Stdout = output
+    fd.Stderr = output
+    start := time.Now()
+    r.Err = fd.Run()
+    r.Duration = time.Since(start)
+    r.Output = output.Bytes()
+    r.Passed = r.Err == nil
+    r.FailedPackages = FailedPackages(r.Output)
+}
+
+// FailedPackages is used to find the packages reported as FAIL in the output of go test
+func FailedPackages(output []byte) []string {
+    var packages []string
+    scanner := bufio.NewScanner(bytes.NewReader(output))
+    for scanner.Scan() {
+        fields := strings.Fields(scanner.Text())
+        if len(fields) >= 2 && fields[0] == "FAIL" {
+            packages = append(packages, fields[1])
+        }
+    }
+    return packages
+}
+
+func writeMatrix(w io.Writer, results []*Result) {
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "TOOLCHAIN\tVERSION\tSTATUS\tDURATION\tFAILED")
+    for _, r := range results {
+        failed := strings.Join(r.FailedPackages, ", ")
+        if failed == "" && r.Err != nil && !r.Passed && len(r.Output) == 0 {
+            failed = r.Err.Error()
+        }
+        fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Toolchain, r.Version, r.Status(), r.Duration.Round(time.Millisecond), failed)
+    }
+    tw.Flush()
+}
+
+// exitMatrix is used to run the matrix and exit with 1 if any toolchain fails
+func exitMatrix(args []string, options *Options) {
+    if !RunMatrix(args, options, os.Stdout) {
+        os.Exit(1)
+    }
+}
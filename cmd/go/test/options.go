@@ -0,0 +1,181 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "errors"
+    "fmt"
+    "runtime"
+    "strconv"
+    "strings"
+)
+
+// ErrMatrixReport is returned when the reports are requested for the tests under several toolchains
+var ErrMatrixReport = errors.New("--summary, --junit, --json-report, --retries, --shard and --cover-all can not be used with --go")
+
+// ErrShardRequired is returned when --shard-tests or --timings is specified without --shard
+var ErrShardRequired = errors.New("--shard-tests and --timings require --shard")
+
+// ErrCoverAllRequired is returned when the coverage reports are specified without --cover-all
+var ErrCoverAllRequired = errors.New("--cover-html, --cover-xml and --cover-threshold require --cover-all")
+
+// Options is the flags of gos test, they are taken from the arguments of go test
+type Options struct {
+    // Toolchains is the toolchains to run the tests with, the default go binary is used if it is empty
+    Toolchains []string
+    // Parallel is the number of toolchains tested in parallel
+    Parallel int
+    // Summary prints the counts of each package and the slowest tests
+    Summary bool
+    // JUnit and JSONReport are the paths of the JUnit XML and json reports
+    JUnit      string
+    JSONReport string
+    // Retries is the number of times the failed tests of a package are re-run
+    Retries int
+    // Shard is the portion of the tests to run, ShardTests assigns the tests instead of the packages to the shards,
+    // and Timings is the json reports whose elapsed time is used to balance the shards
+    Shard      *Shard
+    ShardTests bool
+    Timings    []string
+    // CoverAll collects the coverage of the module into a merged profile, CoverHTML and CoverXML are the paths
+    // of the HTML and Cobertura XML reports, and the run fails if the total coverage is below CoverThreshold
+    CoverAll       bool
+    CoverHTML      string
+    CoverXML       string
+    CoverThreshold float64
+}
+
+// Reporting is used to determine whether go test is run with -json to report the results
+func (o *Options) Reporting() bool {
+    return o.Summary || o.JUnit != "" || o.JSONReport != "" || o.Retries != 0
+}
+
+// TakeFlags is used to take the flags of gos from the arguments, the remaining arguments are passed to go test.
+// The arguments after -args are passed to the test binary and they are kept
+func TakeFlags(args []string) ([]string, *Options, error) {
+    options := &Options{Parallel: runtime.NumCPU()}
+    var r []string
+    for i := 0; i < len(args); i++ {
+        arg := args[i]
+        if arg == "-args" || arg == "--args" {
+            r = append(r, args[i:]...)
+            break
+        }
+        name, value, hasValue := splitFlag(arg)
+        takeValue := func() (string, error) {
+            if hasValue {
+                return value, nil
+            }
+            if i+1 >= len(args) {
+                return "", fmt.Errorf("flag needs an argument: %s", arg)
+            }
+            i++
+            return args[i], nil
+        }
+        switch name {
+        case "--go":
+            value, err := takeValue()
+            if err != nil {
+                return nil, nil, err
+            }
+            for _, spec := range strings.Split(value, ",") {
+                if spec = strings.TrimSpace(spec); spec != "" {
+                    options.Toolchains = append(options.Toolchains, spec)
+                }
+            }
+        case "--go-parallel", "--retries":
+            value, err := takeValue()
+            if err != nil {
+                return nil, nil, err
+            }
+            n, err := strconv.Atoi(value)
+            if err != nil || n < 0 || (n == 0 && name == "--go-parallel") {
+                return nil, nil, fmt.Errorf("invalid value %q for %s, expected a positive number", value, name)
+            }
+            if name == "--retries" {
+                options.Retries = n
+            } else {
+                options.Parallel = n
+            }
+        case "--summary":
+            options.Summary = true
+        case "--shard-tests":
+            options.ShardTests = true
+        case "--cover-all":
+            options.CoverAll = true
+        case "--cover-html", "--cover-xml", "--cover-threshold":
+            value, err := takeValue()
+            if err != nil {
+                return nil, nil, err
+            }
+            switch name {
+            case "--cover-html":
+                options.CoverHTML = value
+            case "--cover-xml":
+                options.CoverXML = value
+            default:
+                threshold, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+                if err != nil || threshold < 0 || threshold > 100 {
+                    return nil, nil, fmt.Errorf("invalid value %q for %s, expected a percentage", value, name)
+                }
+                options.CoverThreshold = threshold
+            }
+        case "--shard", "--timings":
+            value, err := takeValue()
+            if err != nil {
+                return nil, nil, err
+            }
+            if name == "--timings" {
+                options.Timings = append(options.Timings, strings.Split(value, ",")...)
+                break
+            }
+            if options.Shard, err = ParseShard(value); err != nil {
+                return nil, nil, err
+            }
+        case "--junit", "--json-report":
+            value, err := takeValue()
+            if err != nil {
+                return nil, nil, err
+            }
+            if name == "--junit" {
+                options.JUnit = value
+            } else {
+                options.JSONReport = value
+            }
+        default:
+            r = append(r, arg)
+        }
+    }
+    if options.Shard == nil && (options.ShardTests || len(options.Timings) != 0) {
+        return nil, nil, ErrShardRequired
+    }
+    if !options.CoverAll && (options.CoverHTML != "" || options.CoverXML != "" || options.CoverThreshold != 0) {
+        return nil, nil, ErrCoverAllRequired
+    }
+    return r, options, nil
+}
+
+// splitFlag is used to split --name=value into its name and value
+func splitFlag(arg string) (string, string, bool) {
+    if !strings.HasPrefix(arg, "--") {
+        return arg, "", false
+    }
+    if i := strings.Index(arg, "="); i != -1 {
+        return arg[:i], arg[i+1:], true
+    }
+    return arg, "", false
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestTakeFlags(t *testing.T) {
+    args, options, err := TakeFlags([]string{"-v", "--go", "1.20,tip-local", "--go-parallel=2", "./...", "-args", "--go", "x"})
+    assert.NoError(t, err)
+    assert.Equal(t, []string{"-v", "./...", "-args", "--go", "x"}, args)
+    assert.Equal(t, []string{"1.20", "tip-local"}, options.Toolchains)
+    assert.Equal(t, 2, options.Parallel)
+
+    _, _, err = TakeFlags([]string{"--go"})
+    assert.Error(t, err)
+    _, _, err = TakeFlags([]string{"--go-parallel=0"})
+    assert.Error(t, err)
+}
+
+func TestFailedPackages(t *testing.T) {
+    output := "--- FAIL: TestX (0.00s)\nFAIL\nFAIL\texample.com/m\t0.01s\nok  \texample.com/m/a\t0.01s\nFAIL\texample.com/m/b [build failed]\n"
+    assert.Equal(t, []string{"example.com/m", "example.com/m/b"}, FailedPackages([]byte(output)))
+}
@@ -0,0 +1,375 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "github.com/json-iterator/go"
+)
+
+// The actions of test2json events and the status of tests and packages
+const (
+    ActionRun    = "run"
+    ActionOutput = "output"
+    ActionPass   = "pass"
+    ActionFail   = "fail"
+    ActionSkip   = "skip"
+)
+
+// SlowestTests is the number of the slowest tests printed in the summary
+var SlowestTests = 5
+
+// Event is an event printed by go test -json, see go doc test2json
+type Event struct {
+    Time    time.Time `json:"Time"`
+    Action  string    `json:"Action"`
+    Package string    `json:"Package"`
+    Test    string    `json:"Test"`
+    Elapsed float64   `json:"Elapsed"`
+    Output  string    `json:"Output"`
+    // ImportPath is the package of the build output
+    ImportPath string `json:"ImportPath"`
+}
+
+// TestReport is the result of a test
+type TestReport struct {
+    Name    string  `json:"name"`
+    Status  string  `json:"status"`
+    Elapsed float64 `json:"elapsed"`
+    Output  string  `json:"output,omitempty"`
+    // Flaky means the test failed but passed on a retry, Attempts is the number of runs of a retried test
+    Flaky    bool `json:"flaky,omitempty"`
+    Attempts int  `json:"attempts,omitempty"`
+    // FailedOutput is the output of the failed runs before the retry
+    FailedOutput string `json:"failed_output,omitempty"`
+}
+
+// PackageReport is the result of the tests of a package
+type PackageReport struct {
+    Name    string        `json:"name"`
+    Status  string        `json:"status"`
+    Elapsed float64       `json:"elapsed"`
+    Start   time.Time     `json:"start"`
+    Tests   []*TestReport `json:"tests,omitempty"`
+    // Output is the output which does not belong to a test
+    Output string `json:"output,omitempty"`
+
+    tests map[string]*TestReport
+}
+
+// Count is used to count the tests of the status
+func (p *PackageReport) Count(status string) int {
+    n := 0
+    for _, test := range p.Tests {
+        if test.Status == status {
+            n++
+        }
+    }
+    return n
+}
+
+// FlakyTests is used to get the tests which pass on a retry
+func (p *PackageReport) FlakyTests() []*TestReport {
+    var tests []*TestReport
+    for _, test := range p.Tests {
+        if test.Flaky {
+            tests = append(tests, test)
+        }
+    }
+    return tests
+}
+
+// Report is the result of go test collected from the test2json events
+type Report struct {
+    Packages []*PackageReport `json:"packages"`
+    Passed   int              `json:"passed"`
+    Failed   int              `json:"failed"`
+    Skipped  int              `json:"skipped"`
+    Flaky    int              `json:"flaky"`
+    Elapsed  float64          `json:"elapsed"`
+
+    packages map[string]*PackageReport
+}
+
+// NewReport is used to initialize an empty Report
+func NewReport() *Report {
+    return &Report{packages: map[string]*PackageReport{}}
+}
+
+func (r *Report) getPackage(name string, t time.Time) *PackageReport {
+    p, ok := r.packages[name]
+    if !ok {
+        p = &PackageReport{Name: name, Start: t, tests: map[string]*TestReport{}}
+        r.packages[name] = p
+        r.Packages = append(r.Packages, p)
+    }
+    return p
+}
+
+// Add is used to add a test2json event to the report
+func (r *Report) Add(e *Event) {
+    if e.Action == ActionBuildOutput {
+        // the build output is reported by the import path such as "pkg [pkg.test]"
+        name := strings.SplitN(e.ImportPath, " ", 2)[0]
+        if name != "" {
+            r.getPackage(name, e.Time).Output += e.Output
+        }
+        return
+    }
+    if e.Package == "" {
+        return
+    }
+    p := r.getPackage(e.Package, e.Time)
+    if e.Test == "" {
+        switch e.Action {
+        case ActionOutput:
+            p.Output += e.Output
+        case ActionPass, ActionFail, ActionSkip:
+            p.Status = e.Action
+            p.Elapsed = e.Elapsed
+        }
+        return
+    }
+    test, ok := p.tests[e.Test]
+    if !ok {
+        test = &TestReport{Name: e.Test}
+        p.tests[e.Test] = test
+        p.Tests = append(p.Tests, test)
+    }
+    switch e.Action {
+    case ActionOutput:
+        test.Output += e.Output
+    case ActionPass, ActionFail, ActionSkip:
+        test.Status = e.Action
+        test.Elapsed = e.Elapsed
+    }
+}
+
+// Append is used to add the packages of the report of another run,
+// the tests of a package which is also in this report are added to it
+func (r *Report) Append(other *Report) {
+    for _, p := range other.Packages {
+        current, ok := r.packages[p.Name]
+        if !ok {
+            r.packages[p.Name] = p
+            r.Packages = append(r.Packages, p)
+            continue
+        }
+        for _, test := range p.Tests {
+            if _, ok := current.tests[test.Name]; !ok {
+                current.tests[test.Name] = test
+                current.Tests = append(current.Tests, test)
+            }
+        }
+        current.Output += p.Output
+        current.Elapsed += p.Elapsed
+        if p.Status == ActionFail {
+            current.Status = ActionFail
+        }
+    }
+}
+
+// Finish is used to count the results after all the events are added,
+// the tests which do not finish, such as those interrupted by a panic or timeout, are failed
+func (r *Report) Finish() {
+    sort.SliceStable(r.Packages, func(i, j int) bool {
+        return r.Packages[i].Name < r.Packages[j].Name
+    })
+    r.Passed, r.Failed, r.Skipped, r.Flaky, r.Elapsed = 0, 0, 0, 0, 0
+    for _, p := range r.Packages {
+        if p.Status == "" {
+            p.Status = ActionFail
+        }
+        for _, test := range p.Tests {
+            if test.Status == "" {
+                test.Status = ActionFail
+            }
+        }
+        r.Passed += p.Count(ActionPass)
+        r.Failed += p.Count(ActionFail)
+        r.Skipped += p.Count(ActionSkip)
+        r.Flaky += len(p.FlakyTests())
+        r.Elapsed += p.Elapsed
+    }
+}
+
+// FailedPackages is used to get the packages which fail
+func (r *Report) FailedPackages() []*PackageReport {
+    var packages []*PackageReport
+    for _, p := range r.Packages {
+        if p.Status == ActionFail {
+            packages = append(packages, p)
+        }
+    }
+    return packages
+}
+
+// WriteSummary is used to write the counts of each package and the slowest tests
+func (r *Report) WriteSummary(w io.Writer) error {
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "PACKAGE\tSTATUS\tPASSED\tFAILED\tSKIPPED\tELAPSED")
+    for _, p := range r.Packages {
+        fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%.2fs\n",
+            p.Name, p.Status, p.Count(ActionPass), p.Count(ActionFail), p.Count(ActionSkip), p.Elapsed)
+    }
+    fmt.Fprintf(tw, "TOTAL\t\t%d\t%d\t%d\t%.2fs\n", r.Passed, r.Failed, r.Skipped, r.Elapsed)
+    if err := tw.Flush(); err != nil {
+        return err
+    }
+    if r.Flaky != 0 {
+        fmt.Fprintln(w, "\nFLAKY TESTS")
+        for _, p := range r.Packages {
+            for _, test := range p.FlakyTests() {
+                fmt.Fprintf(w, "    %s %s (passed on attempt %d)\n", p.Name, test.Name, test.Attempts)
+            }
+        }
+    }
+
+    type slowTest struct {
+        pkg  string
+        test *TestReport
+    }
+    var tests []slowTest
+    for _, p := range r.Packages {
+        for _, test := range p.Tests {
+            if !strings.Contains(test.Name, "/") && test.Status != ActionSkip {
+                tests = append(tests, slowTest{p.Name, test})
+            }
+        }
+    }
+    if len(tests) == 0 || SlowestTests <= 0 {
+        return nil
+    }
+    sort.SliceStable(tests, func(i, j int) bool {
+        return tests[i].test.Elapsed > tests[j].test.Elapsed
+    })
+    if len(tests) > SlowestTests {
+        tests = tests[:SlowestTests]
+    }
+    fmt.Fprintln(w, "\nSLOWEST TESTS")
+    for _, t := range tests {
+        fmt.Fprintf(w, "%8.2fs  %s %s\n", t.test.Elapsed, t.pkg, t.test.Name)
+    }
+    return nil
+}
+
+// WriteJSON is used to write the report in json format
+func (r *Report) WriteJSON(w io.Writer) error {
+    encoder := jsoniter.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(r)
+}
+
+type junitTestSuites struct {
+    XMLName  xml.Name          `xml:"testsuites"`
+    Tests    int               `xml:"tests,attr"`
+    Failures int               `xml:"failures,attr"`
+    Skipped  int               `xml:"skipped,attr"`
+    Time     string            `xml:"time,attr"`
+    Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+    Name      string           `xml:"name,attr"`
+    Tests     int              `xml:"tests,attr"`
+    Failures  int              `xml:"failures,attr"`
+    Skipped   int              `xml:"skipped,attr"`
+    Time      string           `xml:"time,attr"`
+    Timestamp string           `xml:"timestamp,attr,omitempty"`
+    Cases     []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+    ClassName string        `xml:"classname,attr"`
+    Name      string        `xml:"name,attr"`
+    Time      string        `xml:"time,attr"`
+    Failure   *junitMessage `xml:"failure,omitempty"`
+    Skipped   *junitMessage `xml:"skipped,omitempty"`
+    SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+    Message  string `xml:"message,attr"`
+    Contents string `xml:",chardata"`
+}
+
+func junitTime(seconds float64) string {
+    return fmt.Sprintf("%.3f", seconds)
+}
+
+// WriteJUnit is used to write the report in JUnit XML format, a testsuite per package.
+// A package which fails without a failed test, such as a build failure, is reported as a failed TestMain
+func (r *Report) WriteJUnit(w io.Writer) error {
+    suites := &junitTestSuites{Time: junitTime(r.Elapsed)}
+    for _, p := range r.Packages {
+        suite := &junitTestSuite{Name: p.Name, Time: junitTime(p.Elapsed)}
+        if !p.Start.IsZero() {
+            suite.Timestamp = p.Start.UTC().Format("2006-01-02T15:04:05")
+        }
+        for _, test := range p.Tests {
+            c := &junitTestCase{ClassName: p.Name, Name: test.Name, Time: junitTime(test.Elapsed)}
+            switch test.Status {
+            case ActionFail:
+                c.Failure = &junitMessage{Message: "Failed", Contents: test.Output}
+            case ActionSkip:
+                c.Skipped = &junitMessage{Message: "Skipped", Contents: test.Output}
+            }
+            if test.Flaky {
+                c.SystemOut = fmt.Sprintf("flaky: passed on attempt %d\n%s", test.Attempts, test.FailedOutput)
+            }
+            suite.Cases = append(suite.Cases, c)
+        }
+        if p.Status == ActionFail && p.Count(ActionFail) == 0 {
+            suite.Cases = append(suite.Cases, &junitTestCase{
+                ClassName: p.Name,
+                Name:      "TestMain",
+                Time:      junitTime(p.Elapsed),
+                Failure:   &junitMessage{Message: "Failed", Contents: p.Output},
+            })
+        }
+        for _, c := range suite.Cases {
+            suite.Tests++
+            if c.Failure != nil {
+                suite.Failures++
+            }
+            if c.Skipped != nil {
+                suite.Skipped++
+            }
+        }
+        suites.Tests += suite.Tests
+        suites.Failures += suite.Failures
+        suites.Skipped += suite.Skipped
+        suites.Suites = append(suites.Suites, suite)
+    }
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+    }
+    encoder := xml.NewEncoder(w)
+    encoder.Indent("", "  ")
+    if err := encoder.Encode(suites); err != nil {
+        return err
+    }
+    _, err := io.WriteString(w, "\n")
+    return err
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+const events = `{"Action":"start","Package":"example.com/m/a"}
+{"Action":"run","Package":"example.com/m/a","Test":"TestOK"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestOK","Output":"    a_test.go:8: hidden\n"}
+{"Action":"pass","Package":"example.com/m/a","Test":"TestOK","Elapsed":0.5}
+{"Action":"run","Package":"example.com/m/a","Test":"TestFail"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestFail","Output":"=== RUN   TestFail\n"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestFail","Output":"    a_test.go:9: boom\n"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestFail","Output":"--- FAIL: TestFail (0.10s)\n"}
+{"Action":"fail","Package":"example.com/m/a","Test":"TestFail","Elapsed":0.1}
+{"Action":"run","Package":"example.com/m/a","Test":"TestSkip"}
+{"Action":"skip","Package":"example.com/m/a","Test":"TestSkip"}
+{"Action":"run","Package":"example.com/m/a","Test":"TestPanic"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestPanic","Output":"=== RUN   TestPanic\n"}
+{"Action":"output","Package":"example.com/m/a","Test":"TestPanic","Output":"panic: runtime error\n"}
+{"Action":"output","Package":"example.com/m/a","Output":"FAIL\n"}
+{"Action":"output","Package":"example.com/m/a","Output":"FAIL\texample.com/m/a\t0.6s\n"}
+{"Action":"fail","Package":"example.com/m/a","Elapsed":0.6}
+not an event
+{"ImportPath":"example.com/m/c [example.com/m/c.test]","Action":"build-output","Output":"c_test.go:3:27: undefined: undefined\n"}
+{"Action":"output","Package":"example.com/m/c","Output":"FAIL\texample.com/m/c [build failed]\n"}
+{"Action":"fail","Package":"example.com/m/c","Elapsed":0}
+`
+
+func collect(verbose bool) (*Report, string) {
+    report := NewReport()
+    output := &bytes.Buffer{}
+    p := newPrinter(output, verbose, false)
+    w := &eventWriter{handle: func(line []byte, e *Event) {
+        if e != nil {
+            report.Add(e)
+        }
+        p.Print(line, e)
+    }}
+    w.Write([]byte(events))
+    w.Flush()
+    report.Finish()
+    return report, output.String()
+}
+
+func TestReport_Collect(t *testing.T) {
+    report, output := collect(false)
+    assert.Equal(t, "    a_test.go:9: boom\n--- FAIL: TestFail (0.10s)\nFAIL\nFAIL\texample.com/m/a\t0.6s\npanic: runtime error\nnot an event\n"+
+        "c_test.go:3:27: undefined: undefined\nFAIL\texample.com/m/c [build failed]\n", output)
+    assert.Equal(t, 1, report.Passed)
+    assert.Equal(t, 2, report.Failed, "the unfinished TestPanic is failed")
+    assert.Equal(t, 1, report.Skipped)
+    assert.Len(t, report.FailedPackages(), 2)
+
+    _, output = collect(true)
+    assert.True(t, strings.HasPrefix(output, "=== RUN   TestOK\n    a_test.go:8: hidden\n"))
+
+    junit := &bytes.Buffer{}
+    assert.NoError(t, report.WriteJUnit(junit))
+    assert.Contains(t, junit.String(), `<testsuites tests="5" failures="3" skipped="1" time="0.600">`)
+    assert.Contains(t, junit.String(), `<testcase classname="example.com/m/c" name="TestMain" time="0.000">`)
+    assert.Contains(t, junit.String(), `undefined: undefined`)
+
+    summary := &bytes.Buffer{}
+    assert.NoError(t, report.WriteSummary(summary))
+    assert.Contains(t, summary.String(), "SLOWEST TESTS\n    0.50s  example.com/m/a TestOK\n")
+}
+
+func TestReport_Append(t *testing.T) {
+    report, other := NewReport(), NewReport()
+    for _, e := range []*Event{
+        {Action: ActionPass, Package: "a", Test: "TestA", Elapsed: 1},
+        {Action: ActionPass, Package: "a", Elapsed: 1},
+    } {
+        report.Add(e)
+    }
+    for _, e := range []*Event{
+        {Action: ActionFail, Package: "a", Test: "TestB", Elapsed: 2},
+        {Action: ActionFail, Package: "a", Elapsed: 2},
+        {Action: ActionPass, Package: "b", Test: "TestC"},
+        {Action: ActionPass, Package: "b"},
+    } {
+        other.Add(e)
+    }
+    report.Append(other)
+    report.Finish()
+    assert.Equal(t, 2, len(report.Packages))
+    assert.Equal(t, ActionFail, report.Packages[0].Status)
+    assert.Equal(t, 3.0, report.Packages[0].Elapsed)
+    assert.Equal(t, 2, report.Passed)
+    assert.Equal(t, 1, report.Failed)
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// boolFlags is the boolean flags of go build and go test, the other flags without "=" take the next argument as value
+var boolFlags = map[string]bool{
+    "a": true, "n": true, "x": true, "v": true, "i": true, "c": true,
+    "race": true, "msan": true, "asan": true, "work": true, "trimpath": true, "linkshared": true,
+    "modcacherw": true, "buildvcs": true, "json": true, "cover": true, "failfast": true,
+    "short": true, "benchmem": true, "fullpath": true,
+}
+
+// SplitArgs is used to split the arguments of go test into the flags, the packages and the arguments after -args
+func SplitArgs(args []string) ([]string, []string, []string) {
+    var flags, packages []string
+    for i := 0; i < len(args); i++ {
+        arg := args[i]
+        if arg == "-args" || arg == "--args" {
+            return flags, packages, args[i:]
+        }
+        if !strings.HasPrefix(arg, "-") || arg == "-" {
+            packages = append(packages, arg)
+            continue
+        }
+        flags = append(flags, arg)
+        name := strings.TrimLeft(arg, "-")
+        if strings.Contains(name, "=") || boolFlags[strings.TrimPrefix(name, "test.")] {
+            continue
+        }
+        if i+1 < len(args) {
+            i++
+            flags = append(flags, args[i])
+        }
+    }
+    return flags, packages, nil
+}
+
+// removeFlags is used to remove the flags and their values from the flags split by SplitArgs
+func removeFlags(flags []string, names ...string) []string {
+    var r []string
+    for i := 0; i < len(flags); i++ {
+        name := strings.TrimPrefix(strings.TrimLeft(flags[i], "-"), "test.")
+        hasValue := strings.Contains(name, "=")
+        name = strings.SplitN(name, "=", 2)[0]
+        removed := false
+        for _, n := range names {
+            removed = removed || n == name
+        }
+        if !removed {
+            r = append(r, flags[i])
+            continue
+        }
+        if !hasValue && !boolFlags[name] {
+            i++
+        }
+    }
+    return r
+}
+
+// RunPattern is used to get the -run pattern which matches the top-level tests exactly
+func RunPattern(tests []string) string {
+    quoted := make([]string, len(tests))
+    for i, test := range tests {
+        quoted[i] = regexp.QuoteMeta(test)
+    }
+    return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// ReplaceArgs is used to get the arguments of go test which run the tests matching the -run pattern of the packages,
+// the flags of the names are removed
+func ReplaceArgs(args []string, packages []string, run string, removed ...string) []string {
+    flags, _, testArgs := SplitArgs(args)
+    flags = removeFlags(flags, append(removed, "run")...)
+    r := append(append(flags, "-run", run), packages...)
+    return append(r, testArgs...)
+}
+
+// RetryArgs is used to get the arguments of go test which run only the tests of the package.
+// The profiles are not written by the retries, so that the profiles of the first run are kept,
+// except that the coverage profile is written to coverprofile if it is not empty
+func RetryArgs(args []string, pkg string, tests []string, coverprofile string) []string {
+    r := ReplaceArgs(args, []string{pkg}, RunPattern(tests),
+        "coverprofile", "cpuprofile", "memprofile", "blockprofile", "mutexprofile", "trace", "o")
+    if coverprofile != "" {
+        r = append([]string{"-coverprofile=" + coverprofile}, r...)
+    }
+    return r
+}
+
+// failedTopTests is used to get the failed top-level tests of the package
+func failedTopTests(p *PackageReport) []string {
+    var tests []string
+    for _, test := range p.Tests {
+        if test.Status == ActionFail && !strings.Contains(test.Name, "/") {
+            tests = append(tests, test.Name)
+        }
+    }
+    return tests
+}
+
+// Retry is used to re-run the failed top-level tests of each failed package up to n times
+// with the arguments of the run which reported them, the tests which pass on a retry are marked as flaky,
+// and a package passes when none of its tests fails. The coverage of the retries is merged into the -coverprofile.
+// It returns whether all the failed packages pass after the retries
+func Retry(report *Report, args []string, n int, w io.Writer) (bool, error) {
+    flags, _, _ := SplitArgs(args)
+    output := flagValue(flags, "coverprofile")
+    coverprofile := ""
+    if output != "" {
+        tmp, err := ioutil.TempDir("", "gos-retry")
+        if err != nil {
+            return false, err
+        }
+        defer os.RemoveAll(tmp)
+        coverprofile = filepath.Join(tmp, "retry.out")
+    }
+    passed := true
+    for _, p := range report.FailedPackages() {
+        for attempt := 2; attempt <= n+1; attempt++ {
+            tests := failedTopTests(p)
+            if len(tests) == 0 {
+                break
+            }
+            fmt.Fprintf(w, "=== RETRY %s %s (attempt %d of %d)\n", p.Name, strings.Join(tests, " "), attempt, n+1)
+            retry := NewReport()
+            if _, err := RunJSON(RetryArgs(args, p.Name, tests, coverprofile), retry, w); err != nil {
+                return false, err
+            }
+            if coverprofile != "" {
+                if err := mergeProfile(output, coverprofile); err != nil {
+                    return false, err
+                }
+            }
+            retry.Finish()
+            for _, rp := range retry.Packages {
+                if rp.Name == p.Name {
+                    p.merge(rp, tests, attempt)
+                }
+            }
+        }
+        if p.Count(ActionFail) == 0 && p.Status == ActionPass {
+            continue
+        }
+        passed = false
+    }
+    return passed, nil
+}
+
+// mergeProfile is used to merge the coverage profile of a retry into the output, the retry profile is removed,
+// nothing is merged if the retry writes no profile, such as when it fails to build
+func mergeProfile(output string, retry string) error {
+    if _, err := os.Stat(retry); os.IsNotExist(err) {
+        return nil
+    }
+    profile := NewProfile()
+    for _, path := range []string{output, retry} {
+        file, err := os.Open(path)
+        if os.IsNotExist(err) {
+            continue
+        }
+        if err != nil {
+            return err
+        }
+        err = profile.Merge(file)
+        file.Close()
+        if err != nil {
+            return err
+        }
+    }
+    profile.Sort()
+    if err := writeFile(output, profile.Write); err != nil {
+        return err
+    }
+    return os.Remove(retry)
+}
+
+// merge is used to replace the results of the retried tests and their sub tests with the results of the retry
+func (p *PackageReport) merge(retry *PackageReport, tests []string, attempt int) {
+    for _, name := range tests {
+        for _, test := range retry.Tests {
+            if test.Name != name && !strings.HasPrefix(test.Name, name+"/") {
+                continue
+            }
+            current, ok := p.tests[test.Name]
+            if !ok {
+                current = &TestReport{Name: test.Name}
+                p.tests[test.Name] = current
+                p.Tests = append(p.Tests, current)
+            }
+            if test.Name == name && test.Status == ActionPass && current.Status == ActionFail {
+                current.Flaky = true
+            }
+            current.FailedOutput += current.failedOutput()
+            current.Status, current.Elapsed, current.Output = test.Status, test.Elapsed, test.Output
+            current.Attempts = attempt
+        }
+    }
+    if retry.Status == ActionPass && p.Count(ActionFail) == 0 {
+        p.Status = ActionPass
+    }
+}
+
+func (t *TestReport) failedOutput() string {
+    if t.Status != ActionFail {
+        return ""
+    }
+    return t.Output
+}
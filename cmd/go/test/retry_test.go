@@ -0,0 +1,93 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestRetryArgs(t *testing.T) {
+    args := []string{"-v", "-run", "Test", "-count=1", "-tags", "prod", "./...", "-coverprofile", "c.out", "-race", "./x", "-args", "-run", "y"}
+    flags, packages, testArgs := SplitArgs(args)
+    assert.Equal(t, []string{"-v", "-run", "Test", "-count=1", "-tags", "prod", "-coverprofile", "c.out", "-race"}, flags)
+    assert.Equal(t, []string{"./...", "./x"}, packages)
+    assert.Equal(t, []string{"-args", "-run", "y"}, testArgs)
+
+    assert.Equal(t, []string{"-v", "-count=1", "-tags", "prod", "-race", "-run", `^(TestA|Test\.B)$`, "example.com/m", "-args", "-run", "y"},
+        RetryArgs(args, "example.com/m", []string{"TestA", "Test.B"}, ""))
+    assert.Equal(t, []string{"-coverprofile=retry.out", "-v", "-count=1", "-tags", "prod", "-race", "-run", "^(TestA)$", "example.com/m", "-args", "-run", "y"},
+        RetryArgs(args, "example.com/m", []string{"TestA"}, "retry.out"))
+}
+
+func TestMergeProfile(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-retry-test")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    output, retry := filepath.Join(dir, "c.out"), filepath.Join(dir, "retry.out")
+    assert.Equal(t, nil, ioutil.WriteFile(output, []byte("mode: set\nm/a.go:1.1,2.2 1 1\nm/b.go:1.1,2.2 1 0\n"), 0644))
+
+    assert.Equal(t, nil, mergeProfile(output, retry))
+    assert.Equal(t, nil, ioutil.WriteFile(retry, []byte("mode: set\nm/b.go:1.1,2.2 1 1\n"), 0644))
+    assert.Equal(t, nil, mergeProfile(output, retry))
+    data, err := ioutil.ReadFile(output)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, "mode: set\nm/a.go:1.1,2.2 1 1\nm/b.go:1.1,2.2 1 1\n", string(data))
+    _, err = os.Stat(retry)
+    assert.True(t, os.IsNotExist(err))
+}
+
+func TestPackageReport_Merge(t *testing.T) {
+    report := NewReport()
+    for _, e := range []*Event{
+        {Action: ActionFail, Package: "m", Test: "TestA/sub"},
+        {Action: ActionOutput, Package: "m", Test: "TestA", Output: "boom\n"},
+        {Action: ActionFail, Package: "m", Test: "TestA"},
+        {Action: ActionFail, Package: "m", Test: "TestB"},
+        {Action: ActionPass, Package: "m", Test: "TestC"},
+        {Action: ActionFail, Package: "m"},
+    } {
+        report.Add(e)
+    }
+    report.Finish()
+    p := report.Packages[0]
+    assert.Equal(t, []string{"TestA", "TestB"}, failedTopTests(p))
+
+    retry := NewReport()
+    for _, e := range []*Event{
+        {Action: ActionPass, Package: "m", Test: "TestA/sub"},
+        {Action: ActionPass, Package: "m", Test: "TestA"},
+        {Action: ActionFail, Package: "m", Test: "TestB"},
+        {Action: ActionFail, Package: "m"},
+    } {
+        retry.Add(e)
+    }
+    retry.Finish()
+    p.merge(retry.Packages[0], []string{"TestA", "TestB"}, 2)
+    report.Finish()
+    assert.Equal(t, ActionFail, p.Status)
+    assert.Equal(t, 1, report.Flaky)
+    assert.Equal(t, 1, report.Failed)
+    a := p.FlakyTests()[0]
+    assert.Equal(t, "TestA", a.Name)
+    assert.Equal(t, 2, a.Attempts)
+    assert.Equal(t, "boom\n", a.FailedOutput)
+}
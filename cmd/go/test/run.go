@@ -0,0 +1,265 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/json-iterator/go"
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// ActionBuildOutput is the action of the build output printed by go test -json since go1.24
+const ActionBuildOutput = "build-output"
+
+// eventWriter splits the output of go test -json into lines and decodes the events,
+// the lines which are not events are passed with a nil event
+type eventWriter struct {
+    buf    []byte
+    handle func(line []byte, e *Event)
+}
+
+func (w *eventWriter) Write(data []byte) (int, error) {
+    w.buf = append(w.buf, data...)
+    for {
+        i := bytes.IndexByte(w.buf, '\n')
+        if i == -1 {
+            return len(data), nil
+        }
+        w.handleLine(w.buf[:i+1])
+        w.buf = w.buf[i+1:]
+    }
+}
+
+// Flush is used to handle the last line without newline
+func (w *eventWriter) Flush() {
+    if len(w.buf) != 0 {
+        w.handleLine(append(w.buf, '\n'))
+        w.buf = nil
+    }
+}
+
+func (w *eventWriter) handleLine(line []byte) {
+    e := &Event{}
+    if len(line) == 0 || line[0] != '{' || jsoniter.Unmarshal(line, e) != nil {
+        w.handle(line, nil)
+        return
+    }
+    w.handle(line, e)
+}
+
+// printer renders the events as the output of go test: the output of every test is printed in verbose mode,
+// otherwise only the output of the failed tests and the package results are printed
+type printer struct {
+    w       io.Writer
+    verbose bool
+    // raw prints the lines as they are, it is used when -json is specified
+    raw     bool
+    pending map[string]string
+}
+
+func newPrinter(w io.Writer, verbose bool, raw bool) *printer {
+    return &printer{w: w, verbose: verbose, raw: raw, pending: map[string]string{}}
+}
+
+func (p *printer) Print(line []byte, e *Event) {
+    if p.raw || e == nil {
+        p.w.Write(line)
+        return
+    }
+    if e.Action == ActionBuildOutput {
+        io.WriteString(p.w, e.Output)
+        return
+    }
+    if p.verbose {
+        if e.Action == ActionOutput {
+            io.WriteString(p.w, e.Output)
+        }
+        return
+    }
+    if e.Test == "" {
+        switch e.Action {
+        case ActionOutput:
+            if e.Output != "PASS\n" && !strings.HasPrefix(e.Output, "=== ") {
+                io.WriteString(p.w, e.Output)
+            }
+        case ActionPass, ActionFail:
+            p.flush(e.Package)
+        }
+        return
+    }
+    key := e.Package + " " + e.Test
+    switch e.Action {
+    case ActionOutput:
+        if !strings.HasPrefix(e.Output, "=== ") {
+            p.pending[key] += e.Output
+        }
+    case ActionFail:
+        io.WriteString(p.w, p.pending[key])
+        delete(p.pending, key)
+    case ActionPass, ActionSkip:
+        delete(p.pending, key)
+    }
+}
+
+// flush is used to print the output of the tests which never finish in the package,
+// such as the tests which panic or time out
+func (p *printer) flush(pkg string) {
+    var keys []string
+    for key := range p.pending {
+        if strings.HasPrefix(key, pkg+" ") {
+            keys = append(keys, key)
+        }
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        io.WriteString(p.w, p.pending[key])
+        delete(p.pending, key)
+    }
+}
+
+// hasFlag is used to determine whether the boolean go test flag is specified, such as -v or -json
+func hasFlag(args []string, name string) bool {
+    for _, arg := range args {
+        if arg == "-args" || arg == "--args" {
+            return false
+        }
+        arg = "-" + strings.TrimLeft(arg, "-")
+        if arg == "-"+name || arg == "-"+name+"=true" {
+            return true
+        }
+    }
+    return false
+}
+
+// RunJSON is used to run go test with -json, the events are added to the report and printed to w
+func RunJSON(args []string, report *Report, w io.Writer) (int, error) {
+    raw := hasFlag(args, "json")
+    if !raw {
+        args = append([]string{"-json"}, args...)
+    }
+    p := newPrinter(w, hasFlag(args, "v"), raw)
+    stdout := &eventWriter{handle: func(line []byte, e *Event) {
+        if e != nil {
+            report.Add(e)
+        }
+        p.Print(line, e)
+    }}
+    fd := util.GetGoBinaryCMD("test", args)
+    fd.Env = util.GetEnvWithLocalProxy()
+    fd.Stdout = stdout
+    fd.Stderr = os.Stderr
+    code, err := util.RunCMD(fd)
+    stdout.Flush()
+    return code, err
+}
+
+// RunAll is used to run go test with the arguments of each run, it returns the last non-zero exit code
+func RunAll(runs [][]string) int {
+    code := 0
+    for _, run := range runs {
+        fd := util.GetGoBinaryCMD("test", run)
+        fd.Env = util.GetEnvWithLocalProxy()
+        c, err := util.RunCMD(fd)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "gos: failed to run go test: %s\n", err)
+            return c
+        }
+        if c != 0 {
+            code = c
+        }
+    }
+    return code
+}
+
+// RunReport is used to run go test with the arguments of each run and write the reports by the options,
+// the failed tests of each run are retried with the arguments of the run,
+// the results of the runs are collected into one report, it returns the exit code
+func RunReport(runs [][]string, options *Options) int {
+    report := NewReport()
+    code := 0
+    for _, run := range runs {
+        result := NewReport()
+        c, err := RunJSON(run, result, os.Stdout)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "gos: failed to run go test: %s\n", err)
+            return c
+        }
+        result.Finish()
+        if c != 0 && options.Retries != 0 {
+            passed, err := Retry(result, run, options.Retries, os.Stdout)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "gos: failed to retry go test: %s\n", err)
+            } else if passed {
+                c = 0
+            }
+        }
+        report.Append(result)
+        if c != 0 {
+            code = c
+        }
+    }
+    report.Finish()
+    if err := writeReports(report, options); err != nil {
+        fmt.Fprintf(os.Stderr, "gos: %s\n", err)
+        if code == 0 {
+            code = 1
+        }
+    }
+    return code
+}
+
+func writeReports(report *Report, options *Options) error {
+    if options.Summary {
+        fmt.Println()
+        if err := report.WriteSummary(os.Stdout); err != nil {
+            return err
+        }
+    }
+    if options.JUnit != "" {
+        if err := writeFile(options.JUnit, report.WriteJUnit); err != nil {
+            return err
+        }
+    }
+    if options.JSONReport != "" {
+        if err := writeFile(options.JSONReport, report.WriteJSON); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+    if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+        return err
+    }
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if err := write(file); err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
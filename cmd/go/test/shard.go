@@ -0,0 +1,325 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "bufio"
+    "bytes"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/json-iterator/go"
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// ErrInvalidShard is returned when the shard is not in the format of i/n
+var ErrInvalidShard = errors.New("invalid shard, expected i/n such as 1/4, i is from 1 to n")
+
+// Shard is a portion of the tests, Index is from 1 to Total
+type Shard struct {
+    Index int
+    Total int
+}
+
+// ParseShard is used to parse the shard in the format of i/n
+func ParseShard(value string) (*Shard, error) {
+    parts := strings.Split(value, "/")
+    if len(parts) != 2 {
+        return nil, ErrInvalidShard
+    }
+    index, err := strconv.Atoi(parts[0])
+    if err != nil {
+        return nil, ErrInvalidShard
+    }
+    total, err := strconv.Atoi(parts[1])
+    if err != nil || total < 1 || index < 1 || index > total {
+        return nil, ErrInvalidShard
+    }
+    return &Shard{Index: index, Total: total}, nil
+}
+
+func (s *Shard) String() string {
+    return fmt.Sprintf("%d/%d", s.Index, s.Total)
+}
+
+// Unit is a package or a test of a package which is assigned to a shard
+type Unit struct {
+    Package string
+    // Test is empty if the unit is the whole package
+    Test   string
+    Weight float64
+}
+
+func (u Unit) key() string {
+    if u.Test == "" {
+        return u.Package
+    }
+    return u.Package + " " + u.Test
+}
+
+// Timings is the elapsed seconds of the packages and tests recorded by the json reports
+type Timings map[string]float64
+
+// LoadTimings is used to load the timings from the json reports of gos test --json-report,
+// the later reports override the earlier ones
+func LoadTimings(paths []string) (Timings, error) {
+    timings := Timings{}
+    for _, path := range paths {
+        data, err := ioutil.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        report := &Report{}
+        if err := jsoniter.Unmarshal(data, report); err != nil {
+            return nil, fmt.Errorf("%s: %s", path, err)
+        }
+        for _, p := range report.Packages {
+            timings[p.Name] = p.Elapsed
+            for _, test := range p.Tests {
+                if !strings.Contains(test.Name, "/") {
+                    timings[Unit{Package: p.Name, Test: test.Name}.key()] = test.Elapsed
+                }
+            }
+        }
+    }
+    return timings, nil
+}
+
+// Weigh is used to set the weight of the units by the timings,
+// the units without timing weigh the average of the others, or 1 if there is no timing
+func (t Timings) Weigh(units []Unit) {
+    sum, n := 0.0, 0
+    for _, u := range units {
+        if elapsed, ok := t[u.key()]; ok {
+            sum += elapsed
+            n++
+        }
+    }
+    average := 1.0
+    if n != 0 && sum > 0 {
+        average = sum / float64(n)
+    }
+    for i, u := range units {
+        units[i].Weight = average
+        if elapsed, ok := t[u.key()]; ok && elapsed > 0 {
+            units[i].Weight = elapsed
+        }
+    }
+}
+
+// Assign is used to assign the units to the shards deterministically,
+// the heaviest unit is assigned to the lightest shard first, the ties are broken by the names and the shard index
+func Assign(units []Unit, total int) [][]Unit {
+    sorted := append([]Unit(nil), units...)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        if sorted[i].Weight != sorted[j].Weight {
+            return sorted[i].Weight > sorted[j].Weight
+        }
+        return sorted[i].key() < sorted[j].key()
+    })
+    shards := make([][]Unit, total)
+    loads := make([]float64, total)
+    for _, u := range sorted {
+        lightest := 0
+        for i := range loads {
+            if loads[i] < loads[lightest] {
+                lightest = i
+            }
+        }
+        shards[lightest] = append(shards[lightest], u)
+        loads[lightest] += u.Weight
+    }
+    return shards
+}
+
+// listFlags is the flags of go test which affect the packages and tests listed
+var listFlags = []string{"tags", "mod", "modfile", "race", "msan", "asan"}
+
+// keepFlags is used to keep only the flags of the names and their values from the flags split by SplitArgs
+func keepFlags(flags []string, names []string) []string {
+    var r []string
+    for i := 0; i < len(flags); i++ {
+        name := strings.TrimLeft(flags[i], "-")
+        hasValue := strings.Contains(name, "=")
+        name = strings.SplitN(name, "=", 2)[0]
+        takesValue := !hasValue && !boolFlags[name] && i+1 < len(flags)
+        for _, n := range names {
+            if n == name {
+                r = append(r, flags[i])
+                if takesValue {
+                    r = append(r, flags[i+1])
+                }
+            }
+        }
+        if takesValue {
+            i++
+        }
+    }
+    return r
+}
+
+// flagValue is used to get the value of the flag from the flags split by SplitArgs
+func flagValue(flags []string, name string) string {
+    value := ""
+    for i := 0; i < len(flags); i++ {
+        flag := strings.TrimPrefix(strings.TrimLeft(flags[i], "-"), "test.")
+        if flag == name && i+1 < len(flags) {
+            value = flags[i+1]
+        } else if strings.HasPrefix(flag, name+"=") {
+            value = strings.TrimPrefix(flag, name+"=")
+        }
+    }
+    return value
+}
+
+// ListPackages is used to list the packages with test files by go list through the gos proxy
+func ListPackages(args []string) ([]string, error) {
+    flags, packages, _ := SplitArgs(args)
+    listArgs := append(keepFlags(flags, listFlags), "-f", "{{if or .TestGoFiles .XTestGoFiles}}{{.ImportPath}}{{end}}")
+    output, err := runGo("list", append(listArgs, packages...))
+    if err != nil {
+        return nil, err
+    }
+    var r []string
+    for _, line := range strings.Split(string(output), "\n") {
+        if line = strings.TrimSpace(line); line != "" {
+            r = append(r, line)
+        }
+    }
+    return r, nil
+}
+
+// ListTests is used to list the top-level tests, examples and fuzz tests of the packages by go test -list,
+// the tests are filtered by the -run of the arguments. The packages which fail to build have no test listed
+func ListTests(args []string, packages []string) (map[string][]string, error) {
+    flags, _, _ := SplitArgs(args)
+    pattern := flagValue(flags, "run")
+    if pattern == "" {
+        pattern = "."
+    }
+    listArgs := append(keepFlags(flags, listFlags), "-json", "-list", pattern)
+    output, err := runGo("test", append(listArgs, packages...))
+    if err != nil && len(output) == 0 {
+        return nil, err
+    }
+    tests := map[string][]string{}
+    scanner := bufio.NewScanner(bytes.NewReader(output))
+    for scanner.Scan() {
+        e := &Event{}
+        if jsoniter.Unmarshal(scanner.Bytes(), e) != nil || e.Action != ActionOutput || e.Test != "" {
+            continue
+        }
+        name := strings.TrimSpace(e.Output)
+        for _, prefix := range []string{"Test", "Example", "Fuzz"} {
+            if strings.HasPrefix(name, prefix) && !strings.ContainsAny(name, " \t") {
+                tests[e.Package] = append(tests[e.Package], name)
+            }
+        }
+    }
+    return tests, nil
+}
+
+func runGo(subcmd string, args []string) ([]byte, error) {
+    fd := util.GetGoBinaryCMD(subcmd, args)
+    fd.Env = util.GetEnvWithLocalProxy()
+    stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+    fd.Stdout = stdout
+    fd.Stderr = stderr
+    if err := fd.Run(); err != nil {
+        return stdout.Bytes(), fmt.Errorf("go %s: %s%s", subcmd, stderr.String(), err)
+    }
+    return stdout.Bytes(), nil
+}
+
+// NewUnits is used to get the units of the packages, the tests of the packages are the units if tests is not nil.
+// A package without test listed, such as a package with only TestMain or benchmarks, is one unit,
+// so that it is run as a whole by exactly one shard and its build failure is still reported
+func NewUnits(packages []string, tests map[string][]string) []Unit {
+    var units []Unit
+    for _, pkg := range packages {
+        if len(tests[pkg]) == 0 {
+            units = append(units, Unit{Package: pkg})
+            continue
+        }
+        for _, test := range tests[pkg] {
+            units = append(units, Unit{Package: pkg, Test: test})
+        }
+    }
+    return units
+}
+
+// Plan is used to get the arguments of the go test runs of the shard.
+// The packages are the units by default, and the tests are the units if ShardTests is specified
+func Plan(args []string, options *Options) ([][]string, error) {
+    packages, err := ListPackages(args)
+    if err != nil {
+        return nil, err
+    }
+    var tests map[string][]string
+    if options.ShardTests {
+        if tests, err = ListTests(args, packages); err != nil {
+            return nil, err
+        }
+    }
+    units := NewUnits(packages, tests)
+    timings, err := LoadTimings(options.Timings)
+    if err != nil {
+        return nil, err
+    }
+    timings.Weigh(units)
+    return ShardRuns(args, units, Assign(units, options.Shard.Total)[options.Shard.Index-1]), nil
+}
+
+// ShardRuns is used to get the arguments of the go test runs of the units assigned to the shard,
+// the packages whose units are all in the shard are run together, the others are run by -run with the names
+func ShardRuns(args []string, units []Unit, shard []Unit) [][]string {
+    assigned := map[string][]string{}
+    var order []string
+    for _, u := range shard {
+        if _, ok := assigned[u.Package]; !ok {
+            order = append(order, u.Package)
+        }
+        assigned[u.Package] = append(assigned[u.Package], u.Test)
+    }
+    sort.Strings(order)
+    total := map[string]int{}
+    for _, u := range units {
+        total[u.Package]++
+    }
+
+    flags, _, testArgs := SplitArgs(args)
+    var whole []string
+    var runs [][]string
+    for _, pkg := range order {
+        tests := assigned[pkg]
+        if len(tests) == total[pkg] {
+            whole = append(whole, pkg)
+            continue
+        }
+        sort.Strings(tests)
+        runs = append(runs, ReplaceArgs(args, []string{pkg}, RunPattern(tests)))
+    }
+    if len(whole) != 0 {
+        run := append(append(append([]string{}, flags...), whole...), testArgs...)
+        runs = append([][]string{run}, runs...)
+    }
+    return runs
+}
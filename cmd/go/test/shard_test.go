@@ -0,0 +1,81 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestParseShard(t *testing.T) {
+    shard, err := ParseShard("2/4")
+    assert.NoError(t, err)
+    assert.Equal(t, &Shard{Index: 2, Total: 4}, shard)
+    for _, value := range []string{"0/4", "5/4", "1/0", "1", "a/b"} {
+        _, err := ParseShard(value)
+        assert.Equal(t, ErrInvalidShard, err, value)
+    }
+}
+
+func TestAssign(t *testing.T) {
+    units := []Unit{{Package: "d"}, {Package: "a"}, {Package: "c"}, {Package: "b"}, {Package: "e"}}
+    Timings{}.Weigh(units)
+    shards := Assign(units, 2)
+    assert.Equal(t, []Unit{{Package: "a", Weight: 1}, {Package: "c", Weight: 1}, {Package: "e", Weight: 1}}, shards[0])
+    assert.Equal(t, []Unit{{Package: "b", Weight: 1}, {Package: "d", Weight: 1}}, shards[1])
+
+    Timings{"a": 10, "b": 2, "c x": 1}.Weigh(units)
+    var names [][]string
+    for _, shard := range Assign(units, 2) {
+        var s []string
+        for _, u := range shard {
+            s = append(s, u.key())
+        }
+        names = append(names, s)
+    }
+    // c, d and e weigh the average 6, the heaviest unit goes to the lightest shard
+    assert.Equal(t, [][]string{{"a", "e"}, {"c", "d", "b"}}, names)
+}
+
+func TestShardRuns_PackageWithoutTests(t *testing.T) {
+    // m/main has only TestMain and m/bench has only benchmarks, so no test is listed for them
+    packages := []string{"m/a", "m/bench", "m/main"}
+    units := NewUnits(packages, map[string][]string{"m/a": {"TestA", "TestB", "TestC"}})
+    Timings{}.Weigh(units)
+    assert.Equal(t, 5, len(units))
+
+    args := []string{"-v", "./...", "-args", "-x"}
+    runs := map[string]int{}
+    for _, shard := range Assign(units, 3) {
+        for _, run := range ShardRuns(args, units, shard) {
+            _, packages, testArgs := SplitArgs(run)
+            assert.Equal(t, []string{"-args", "-x"}, testArgs)
+            for _, pkg := range packages {
+                runs[pkg]++
+            }
+        }
+    }
+    assert.Equal(t, map[string]int{"m/a": 3, "m/bench": 1, "m/main": 1}, runs)
+}
+
+func TestKeepFlags(t *testing.T) {
+    flags, _, _ := SplitArgs([]string{"-v", "-tags", "prod", "-run=TestA", "-race", "-count", "1", "./..."})
+    assert.Equal(t, []string{"-tags", "prod", "-race"}, keepFlags(flags, listFlags))
+    assert.Equal(t, "TestA", flagValue(flags, "run"))
+    assert.Equal(t, "1", flagValue(flags, "count"))
+}
@@ -17,6 +17,7 @@
 package test
 
 import (
+    "log"
     "os"
 
     "github.com/spf13/cobra"
@@ -29,126 +30,97 @@ var CmdTest = &cobra.Command{
     Short: "test packages",
     Long: `
 'Go test' automates testing the packages named by the import paths.
-It prints a summary of the test results in the format:
 
-    ok   archive/tar   0.011s
-    FAIL archive/zip   0.022s
-    ok   compress/gzip 0.033s
-    ...
-
-followed by detailed output for each failed package.
-
-'Go test' recompiles each package along with any files with names matching
-the file pattern "*_test.go".
-These additional files can contain test functions, benchmark functions, and
-example functions. See 'go help testfunc' for more.
-Each listed package causes the execution of a separate test binary.
-Files whose names begin with "_" (including "_test.go") or "." are ignored.
-
-Test files that declare a package with the suffix "_test" will be compiled as a
-separate package, and then linked and run with the main test binary.
-
-The go tool will ignore a directory named "testdata", making it available
-to hold ancillary data needed by the tests.
-
-As part of building a test binary, go test runs go vet on the package
-and its test source files to identify significant problems. If go vet
-finds any problems, go test reports those and does not run the test
-binary. Only a high-confidence subset of the default go vet checks are
-used. That subset is: 'atomic', 'bool', 'buildtags', 'nilfunc', and
-'printf'. You can see the documentation for these and other vet tests
-via "go doc cmd/vet". To disable the running of go vet, use the
--vet=off flag.
-
-All test output and summary lines are printed to the go command's
-standard output, even if the test printed them to its own standard
-error. (The go command's standard error is reserved for printing
-errors building the tests.)
-
-Go test runs in two different modes:
-
-The first, called local directory mode, occurs when go test is
-invoked with no package arguments (for example, 'go test' or 'go
-test -v'). In this mode, go test compiles the package sources and
-tests found in the current directory and then runs the resulting
-test binary. In this mode, caching (discussed below) is disabled.
-After the package test finishes, go test prints a summary line
-showing the test status ('ok' or 'FAIL'), package name, and elapsed
-time.
-
-The second, called package list mode, occurs when go test is invoked
-with explicit package arguments (for example 'go test math', 'go
-test ./...', and even 'go test .'). In this mode, go test compiles
-and tests each of the packages listed on the command line. If a
-package test passes, go test prints only the final 'ok' summary
-line. If a package test fails, go test prints the full test output.
-If invoked with the -bench or -v flag, go test prints the full
-output even for passing package tests, in order to display the
-requested benchmark results or verbose logging.
-
-In package list mode only, go test caches successful package test
-results to avoid unnecessary repeated running of tests. When the
-result of a test can be recovered from the cache, go test will
-redisplay the previous output instead of running the test binary
-again. When this happens, go test prints '(cached)' in place of the
-elapsed time in the summary line.
-
-The rule for a match in the cache is that the run involves the same
-test binary and the flags on the command line come entirely from a
-restricted set of 'cacheable' test flags, defined as -cpu, -list,
--parallel, -run, -short, and -v. If a run of go test has any test
-or non-test flags outside this set, the result is not cached. To
-disable test caching, use any test flag or argument other than the
-cacheable flags. The idiomatic way to disable test caching explicitly
-is to use -count=1. Tests that open files within the package's source
-root (usually $GOPATH) or that consult environment variables only
-match future runs in which the files and environment variables are unchanged.
-A cached test result is treated as executing in no time at all,
-so a successful package test result will be cached and reused
-regardless of -timeout setting.
-
-In addition to the build flags, the flags handled by 'go test' itself are:
-
-    -args
-        Pass the remainder of the command line (everything after -args)
-        to the test binary, uninterpreted and unchanged.
-        Because this flag consumes the remainder of the command line,
-        the package list (if present) must appear before this flag.
-
-    -c
-        Compile the test binary to pkg.test but do not run it
-        (where pkg is the last element of the package's import path).
-        The file name can be changed with the -o flag.
-
-    -exec xprog
-        Run the test binary using xprog. The behavior is the same as
-        in 'go run'. See 'go help run' for details.
-
-    -i
-        Install packages that are dependencies of the test.
-        Do not run the test.
-
-    -json
-        Convert test output to JSON suitable for automated processing.
-        See 'go doc test2json' for the encoding details.
-
-    -o file
-        Compile the test binary to the named file.
-        The test still runs (unless -c or -i is specified).
-
-The test binary also accepts flags that control execution of the test; these
-flags are also accessible by 'go test'. See 'go help testflag' for details.
-
-For more about build flags, see 'go help build'.
-For more about specifying packages, see 'go help packages'.
-
-See also: go build, go vet.
+Gos provides additional flags:
+
+    --go toolchain,...
+        run the tests under each toolchain and print a pass/fail matrix with the failed packages,
+        a toolchain is a version installed by gos toolchain (such as 1.21 or go1.21.0),
+        a folder name in the toolchain store (such as tip-local), "local" for the go binary in $PATH,
+        or the path of a go binary or GOROOT
+    --go-parallel n
+        the number of toolchains tested in parallel, the default is the number of CPUs
+    --summary
+        print the passed, failed and skipped tests of each package and the slowest tests
+    --junit file
+        write the results in JUnit XML format
+    --json-report file
+        write the results in json format, the tests of each package with their status, elapsed time and output
+    --retries n
+        re-run the failed top-level tests of each package up to n times by -run with their anchored names,
+        the tests which pass on a retry are reported as flaky, and go test passes if no test fails at last.
+        The retries keep the flags of the run such as -coverpkg, their coverage is merged into the -coverprofile
+    --shard i/n
+        run the i-th of n portions of the packages, i is from 1 to n. The packages with test files are listed
+        by go list and assigned to the shards deterministically, so that each CI node runs a different portion
+    --shard-tests
+        assign the top-level tests of the packages listed by go test -list instead of the packages to the shards
+    --timings file,...
+        balance the shards by the elapsed time recorded in the json reports of previous runs (--json-report),
+        the packages and tests without timing are weighed the average
+    --cover-all
+        collect the coverage of all the packages of the module (-coverpkg=<module>/... unless -coverpkg is specified),
+        merge the profiles of the packages, shards and runs into one and print the coverage of each package and file,
+        the merged profile is written to the -coverprofile if it is specified
+    --cover-html file
+        write the merged coverage in HTML format by go tool cover
+    --cover-xml file
+        write the merged coverage in Cobertura XML format
+    --cover-threshold percent
+        fail if the total coverage is below the percent, such as 80 or 80%
+
+    The reports are collected by running go test -json, the output is still printed as go test does.
 `,
     DisableFlagParsing: true,
 }
 
 func init() {
     CmdTest.Run = func(cmd *cobra.Command, args []string) {
+        args, options, err := TakeFlags(args)
+        if err != nil {
+            log.Println(err)
+            os.Exit(2)
+        }
+        if len(options.Toolchains) != 0 {
+            if options.Reporting() || options.Shard != nil || options.CoverAll {
+                log.Println(ErrMatrixReport)
+                os.Exit(2)
+            }
+            exitMatrix(args, options)
+            return
+        }
+        runs := [][]string{args}
+        if options.Shard != nil {
+            if runs, err = Plan(args, options); err != nil {
+                log.Println(err)
+                os.Exit(1)
+            }
+            if len(runs) == 0 {
+                log.Printf("no tests in shard %s", options.Shard)
+                return
+            }
+        }
+        var cover *Cover
+        if options.CoverAll {
+            if cover, err = NewCover(args); err != nil {
+                log.Println(err)
+                os.Exit(1)
+            }
+            runs = cover.Apply(runs)
+        }
+        if options.Reporting() || options.Shard != nil || cover != nil {
+            var code int
+            if options.Reporting() {
+                code = RunReport(runs, options)
+            } else {
+                code = RunAll(runs)
+            }
+            if cover != nil {
+                code = cover.Report(options, code)
+            }
+            os.Exit(code)
+        }
+
         fd := util.GetGoBinaryCMD("test", args)
         fd.Env = util.GetEnvWithLocalProxy()
         fd.Stdout = os.Stdout
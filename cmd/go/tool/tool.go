@@ -30,11 +30,6 @@ var CmdTool = &cobra.Command{
     Long: `
 Tool runs the go tool command identified by the arguments.
 With no arguments it prints the list of known tools.
-
-The -n flag causes tool to print the command that would be
-executed but not execute it.
-
-For more about each tool command, see 'go doc cmd/<command>'.
 `,
     DisableFlagParsing: true,
 }
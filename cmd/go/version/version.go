@@ -25,16 +25,17 @@ import (
 
 // CmdVersion prints the Go version, as reported by runtime.Version
 var CmdVersion = &cobra.Command{
-    Use:                "version",
-    Short:              "print Go version",
-    Long:               `Version prints the Go version, as reported by runtime.Version.`,
+    Use:   "version",
+    Short: "print Go version",
+    Long: `
+Version prints the Go version, as reported by runtime.Version.
+`,
     DisableFlagParsing: true,
 }
 
 func init() {
     CmdVersion.Run = func(cmd *cobra.Command, args []string) {
         fd := util.GetGoBinaryCMD("version", args)
-        fd.Env = util.GetEnvWithLocalProxy()
         fd.Stdout = os.Stdout
         fd.Stderr = os.Stderr
         util.RunCMDWithExit(fd)
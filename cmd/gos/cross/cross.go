@@ -15,9 +15,12 @@
 package cross
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"runtime"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
@@ -29,13 +32,32 @@ var CmdCross = &cobra.Command{
 	Short: "agile and fast cross compiling",
 	Long: `
 Usage:
-    gos cross [common go build flags] [-e] [go file] [os] [arch]
+    gos cross [common go build flags] [-e] [--json] [--parallel n] [--no-download] [--warm-std] [--cgo] [--cc os/arch=compiler] [--stamp] [go file] [os] [arch]
 
     [common go build flags] you can use any standard flag for go build here, such as /-tags="..."/-a/-o/..., as you would with native go build
-    [-e] when you specify -e, compilation errors will be printed
+    [-e] print the compilation error of each target as soon as it fails, instead of after all targets finish
+    [--json] print the results (target, status, output, size, duration, error) in json format
+    [--parallel n] the number of targets compiled in parallel, the default is the number of CPUs
+    [--no-download] skip downloading the module graph before compiling, which is done once by default
+    [--warm-std] build the std library of each target before compiling
+    [--cgo] enable cgo for the targets whose cross compiler (such as aarch64-linux-gnu-gcc or zig) is found in $PATH
+    [--cc os/arch=compiler] [--cxx os/arch=compiler] [--sysroot os/arch=dir]
+        configure the C toolchain of a target, cgo will be enabled for it,
+        the C compiler is detected in $PATH when --cc is not specified for the target,
+        these flags can be specified multiple times
+    [--stamp[=version=pkg.Var,commit=pkg.Var,date=pkg.Var,platform=pkg.Var]]
+        inject the git describe version, commit, build date and target platform by -ldflags -X,
+        the default variables are main.version, main.commit, main.date and main.platform,
+        the -X flags are merged into the -ldflags you specified
+    [-o] the output path, it can also be a template with the following fields:
+        {{.OS}} {{.Arch}} {{.Arm}} {{.Version}} {{.Name}} {{.Ext}}
+        .Arm is the GOARM of arm targets specified by arm/v5, arm/v6 or arm/v7, .Version is the output of git describe,
+        .Name is the go file name (main.go is named after its folder), .Ext is ".exe" on windows,
+        the directories will be created as needed
     [file] the go file you wanna to build
     [os] the OS such as linux/darwin/windows/freebsd/netbsd/openbsd/android/dragonfly/nacl/solaris/plan9, you can also use "all" to compile all OS
-    [arch] the Arch such as amd64/386/arm/arm64/s390x/mips/mipsle/mips64/mips64le, you can also use "all" to compile all Arch
+    [arch] the Arch such as amd64/386/arm/arm64/s390x/mips/mipsle/mips64/mips64le, you can also use "all" to compile all Arch,
+        the GOARM of arm is specified by arm/v5, arm/v6 or arm/v7
 
     - Compile all platform
     gos cross main.go all all
@@ -51,6 +73,18 @@ Usage:
 
     - Compile with error info printed
     gos cross -e main.go all all
+
+    - Compile on a cold CI runner with 2 parallel builds
+    gos cross --parallel 2 --warm-std main.go linux all
+
+    - Compile with cgo enabled by the cross compilers
+    gos cross --cgo --cc "linux/arm64=zig cc -target aarch64-linux-gnu" main.go linux all
+
+    - Compile with version metadata injected into main.version, main.commit, main.date and main.platform
+    gos cross --stamp -ldflags="-s -w" main.go all all
+
+    - Compile into a dist/ layout
+    gos cross -o "dist/{{.OS}}_{{.Arch}}/{{.Name}}{{.Ext}}" main.go all all
     `,
 	DisableFlagParsing: true,
 }
@@ -71,43 +105,78 @@ func Run(cmd *cobra.Command, args []string) {
 	options, err := NewOptions(args)
 	if err != nil {
 		log.Println(err)
-		return
+		os.Exit(1)
 	}
 
 	horses, err := options.GetCompileWorkhorse()
 	if err != nil {
 		log.Println(err)
-		return
+		os.Exit(1)
+	}
+
+	if !options.SkipDownload {
+		log.Println("* downloading dependencies")
+		if err := options.Download(); err != nil {
+			log.Printf("* failed to download dependencies: %s", err)
+		}
+	}
+
+	if options.WarmStd {
+		log.Println("* building std library")
+		if err := WarmUp(horses, options.Parallel); err != nil && options.ShowErr {
+			log.Println(err)
+		}
 	}
 
 	var wg sync.WaitGroup
 	var lock sync.Mutex
 	var errs error
-	threads := make(chan bool, runtime.NumCPU())
-	for _, horse := range horses {
+	var finished int
+	results := make(Results, len(horses))
+	threads := make(chan bool, options.Parallel)
+	for i, horse := range horses {
 		wg.Add(1)
 		threads <- true
-		go func(horse *Workhorse) {
+		go func(i int, horse *Workhorse) {
 			defer func() {
 				wg.Done()
 				<-threads
 			}()
-			err := horse.Compile()
-			platform := horse.Platform.String()
-			if err != nil {
-				log.Printf("* %s: failed", platform)
-				lock.Lock()
-				defer lock.Unlock()
-				errs = multierror.Append(errs, err)
+			result := horse.Build()
+			lock.Lock()
+			defer lock.Unlock()
+			results[i] = result
+			finished++
+			progress := fmt.Sprintf("[%d/%d]", finished, len(horses))
+			duration := result.Duration.Round(time.Millisecond)
+			if result.Failed() {
+				log.Printf("* %s %s: failed in %s", progress, result.Target, duration)
+				if options.ShowErr {
+					log.Println(result.Error)
+				}
+				errs = multierror.Append(errs, errors.New(result.Error))
 				return
 			}
-			log.Printf("* %s: succeeded", platform)
-		}(horse)
+			log.Printf("* %s %s: succeeded in %s", progress, result.Target, duration)
+		}(i, horse)
 	}
 	wg.Wait()
-	if errs != nil && options.ShowErr {
+	// without -e, the errors are printed after all targets finish
+	if errs != nil && !options.ShowErr {
 		log.Println(errs)
 	}
+
+	if options.JSON {
+		err = results.WriteJSON(os.Stdout)
+	} else {
+		err = results.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	if results.Failed() != 0 {
+		os.Exit(1)
+	}
 }
 
 func printUsage() {
@@ -16,8 +16,15 @@ package cross
 
 import (
     "errors"
+    "fmt"
+    "path/filepath"
     "runtime"
+    "strconv"
     "strings"
+    "text/template"
+
+    "github.com/storyicon/gos/pkg/stamp"
+    "github.com/storyicon/gos/pkg/vcs"
 )
 
 // Here defines a set of standard errors
@@ -26,6 +33,8 @@ var (
     ErrMissingGoFile     = errors.New("missing target .go file")
     ErrUnexpectedParams  = errors.New("unexpected list of parameters")
     ErrNoMatchedPlatform = errors.New("no matched platform")
+    ErrInvalidParallel   = errors.New("invalid value of --parallel")
+    ErrInvalidArm        = errors.New("invalid arm variant, expected arm/v5, arm/v6 or arm/v7")
 )
 
 // Options defines the structure of compilation options
@@ -40,8 +49,34 @@ type Options struct {
     Output  string
     Package string
     Platform
+    // * Arm is the GOARM of the arm targets, it is specified by the arch such as arm/v6
+    Arm string
     // * ShowErr will be true when the -e identifier is present
     ShowErr bool
+    // * JSON will be true when the --json identifier is present
+    JSON bool
+    // * Parallel is the maximum number of concurrent compilations, set by --parallel
+    Parallel int
+    // * SkipDownload will be true when the --no-download identifier is present,
+    // * otherwise the module graph is downloaded once before compiling
+    SkipDownload bool
+    // * WarmStd will be true when the --warm-std identifier is present,
+    // * the std library will be built for each target before compiling
+    WarmStd bool
+    // * CGO will be true when the --cgo identifier is present,
+    // * cgo will be enabled for the targets whose cross compiler can be found in $PATH
+    CGO bool
+    // * Toolchains is the C toolchains configured by --cc/--cxx/--sysroot
+    Toolchains Toolchains
+    // * Stamp is used to inject version metadata by -ldflags -X,
+    // * it is not nil when the --stamp identifier is present
+    Stamp *stamp.Stamp
+
+    // Template is the parsed output template when -o contains "{{"
+    Template *template.Template
+    // Version is the output of git describe, it is only resolved
+    // when the output template refers to it
+    Version string
 
     raw []string
 }
@@ -54,7 +89,9 @@ func NewOptions(args []string) (*Options, error) {
             OS:   runtime.GOOS,
             Arch: runtime.GOARCH,
         },
-        raw: args,
+        Parallel:   runtime.NumCPU(),
+        Toolchains: Toolchains{},
+        raw:        args,
     }
 
     var pos uint8
@@ -69,6 +106,65 @@ func NewOptions(args []string) (*Options, error) {
                 continue
             }
 
+            // * --json hook
+            // print the results in json format
+            if arg == "--json" || arg == "-json" {
+                options.JSON = true
+                continue
+            }
+
+            // * --parallel hook
+            if value, ok, err := takeValue(args, &i, "--parallel"); ok {
+                if err != nil {
+                    return nil, err
+                }
+                n, err := strconv.Atoi(value)
+                if err != nil || n < 1 {
+                    return nil, ErrInvalidParallel
+                }
+                options.Parallel = n
+                continue
+            }
+
+            // * --cgo hook
+            if arg == "--cgo" {
+                options.CGO = true
+                continue
+            }
+
+            // * --cc/--cxx/--sysroot hook
+            if kind, value, ok, err := takeToolchain(args, &i); ok {
+                if err != nil {
+                    return nil, err
+                }
+                if err := options.Toolchains.Set(kind, value); err != nil {
+                    return nil, err
+                }
+                continue
+            }
+
+            // * --stamp hook
+            if arg == stamp.Flag || strings.HasPrefix(arg, stamp.Flag+"=") {
+                variables, err := stamp.ParseVariables(strings.TrimPrefix(arg[len(stamp.Flag):], "="))
+                if err != nil {
+                    return nil, err
+                }
+                options.Stamp = &stamp.Stamp{Variables: *variables}
+                continue
+            }
+
+            // * --no-download hook
+            if arg == "--no-download" {
+                options.SkipDownload = true
+                continue
+            }
+
+            // * --warm-std hook
+            if arg == "--warm-std" {
+                options.WarmStd = true
+                continue
+            }
+
             // * -o hook
             if arg == "-o" {
                 i++
@@ -102,9 +198,83 @@ func NewOptions(args []string) (*Options, error) {
         return nil, ErrMissingGoFile
     }
 
+    arch, arm, err := parseArch(options.Arch)
+    if err != nil {
+        return nil, err
+    }
+    options.Arch, options.Arm = arch, arm
+
+    if err := options.prepareTemplate(); err != nil {
+        return nil, err
+    }
+
+    if options.Stamp != nil {
+        options.Stamp = stamp.New(&options.Stamp.Variables, filepath.Dir(options.Package))
+    }
+
     return options, nil
 }
 
+// parseArch is used to split the arch such as arm/v6 into arm and its GOARM 6
+func parseArch(arch string) (string, string, error) {
+    i := strings.Index(arch, "/")
+    if i == -1 {
+        return arch, "", nil
+    }
+    switch variant := arch[i+1:]; {
+    case arch[:i] == "arm" && (variant == "v5" || variant == "v6" || variant == "v7"):
+        return "arm", variant[1:], nil
+    }
+    return "", "", ErrInvalidArm
+}
+
+// takeValue is used to get the value of flag name from "--name value" or "--name=value"
+func takeValue(args []string, i *int, name string) (string, bool, error) {
+    arg := args[*i]
+    if strings.HasPrefix(arg, name+"=") {
+        return arg[len(name)+1:], true, nil
+    }
+    if arg != name {
+        return "", false, nil
+    }
+    if *i++; *i >= len(args) {
+        return "", true, ErrUnexpectedParams
+    }
+    return args[*i], true, nil
+}
+
+// takeToolchain is used to get the value of --cc/--cxx/--sysroot
+func takeToolchain(args []string, i *int) (string, string, bool, error) {
+    for _, kind := range []string{"cc", "cxx", "sysroot"} {
+        if value, ok, err := takeValue(args, i, "--"+kind); ok {
+            return kind, value, true, err
+        }
+    }
+    return "", "", false, nil
+}
+
+// prepareTemplate is used to parse the output template
+// and resolve the version when it is referenced
+func (o *Options) prepareTemplate() error {
+    if !IsOutputTemplate(o.Output) {
+        return nil
+    }
+    tmpl, err := NewOutputTemplate(o.Output)
+    if err != nil {
+        return fmt.Errorf("invalid output template: %s", err)
+    }
+    o.Template = tmpl
+
+    if strings.Contains(o.Output, ".Version") {
+        version, err := vcs.Describe(filepath.Dir(o.Package))
+        if err != nil {
+            return fmt.Errorf("failed to get version: %s", err)
+        }
+        o.Version = version
+    }
+    return nil
+}
+
 // GetCompileWorkhorse is used to get all compilation tasks
 func (o *Options) GetCompileWorkhorse() ([]*Workhorse, error) {
     platforms := allPlatforms
@@ -122,12 +292,38 @@ func (o *Options) GetCompileWorkhorse() ([]*Workhorse, error) {
     }
 
     for _, platform := range platforms {
+        toolchain, err := o.getToolchain(platform)
+        if err != nil {
+            return nil, err
+        }
         horses = append(horses, &Workhorse{
             Package:    o.Package,
             StandardGO: o.StandardGO,
             Output:     o.Output,
             Platform:   platform,
+            Arm:        o.getArm(platform),
+            Template:   o.Template,
+            Version:    o.Version,
+            Toolchain:  toolchain,
+            Stamp:      o.Stamp,
         })
     }
     return horses, nil
 }
+
+// getArm is used to get the GOARM of the platform, it is empty for the other architectures
+func (o *Options) getArm(platform Platform) string {
+    if platform.Arch != "arm" {
+        return ""
+    }
+    return o.Arm
+}
+
+// getToolchain is used to get the C toolchain of the platform,
+// it is nil when cgo should not be enabled by gos
+func (o *Options) getToolchain(platform Platform) (*Toolchain, error) {
+    if platform.OS == runtime.GOOS && platform.Arch == runtime.GOARCH {
+        return nil, nil
+    }
+    return o.Toolchains.Get(platform, o.CGO)
+}
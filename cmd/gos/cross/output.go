@@ -0,0 +1,92 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cross
+
+import (
+    "bytes"
+    "path/filepath"
+    "strings"
+    "text/template"
+)
+
+// OutputFields defines the fields that can be used in the output template,
+// such as "dist/{{.OS}}_{{.Arch}}/{{.Name}}{{.Ext}}"
+type OutputFields struct {
+    // OS is the target operating system, such as linux
+    OS string
+    // Arch is the target architecture, such as amd64
+    Arch string
+    // Arm is the GOARM of the arm target specified by the arch such as arm/v6, otherwise empty
+    Arm string
+    // Version is the output of git describe
+    Version string
+    // Name is the name of the package being compiled
+    Name string
+    // Ext is ".exe" on windows, otherwise empty
+    Ext string
+}
+
+// IsOutputTemplate is used to determine whether the -o argument is a template
+func IsOutputTemplate(output string) bool {
+    return strings.Contains(output, "{{")
+}
+
+// NewOutputTemplate is used to parse the -o argument as a template
+func NewOutputTemplate(text string) (*template.Template, error) {
+    return template.New("output").Option("missingkey=error").Parse(text)
+}
+
+// NewOutputFields is used to get the template fields of the specified platform and its GOARM
+func NewOutputFields(pkg string, version string, platform Platform, arm string) *OutputFields {
+    fields := &OutputFields{
+        OS:      platform.OS,
+        Arch:    platform.Arch,
+        Version: version,
+        Name:    GetPackageName(pkg),
+    }
+    if platform.Arch == "arm" {
+        fields.Arm = arm
+    }
+    if platform.OS == "windows" {
+        fields.Ext = ".exe"
+    }
+    return fields
+}
+
+// GetPackageName is used to get the binary name of the specified go file,
+// it is the file name without extension, and main.go is named after its folder
+func GetPackageName(pkg string) string {
+    name := strings.TrimSuffix(filepath.Base(pkg), filepath.Ext(pkg))
+    if name != "main" {
+        return name
+    }
+    abs, err := filepath.Abs(pkg)
+    if err != nil {
+        return name
+    }
+    if dir := filepath.Base(filepath.Dir(abs)); dir != string(filepath.Separator) && dir != "." {
+        return dir
+    }
+    return name
+}
+
+// ExecuteOutputTemplate is used to render the output template with fields
+func ExecuteOutputTemplate(tmpl *template.Template, fields *OutputFields) (string, error) {
+    buf := &bytes.Buffer{}
+    if err := tmpl.Execute(buf, fields); err != nil {
+        return "", err
+    }
+    return filepath.FromSlash(buf.String()), nil
+}
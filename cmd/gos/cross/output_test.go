@@ -0,0 +1,102 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cross
+
+import (
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestExecuteOutputTemplate(t *testing.T) {
+    tests := []struct {
+        name     string
+        template string
+        pkg      string
+        version  string
+        platform Platform
+        arm      string
+        want     string
+    }{
+        {
+            name:     "test0",
+            template: "dist/{{.OS}}_{{.Arch}}/{{.Name}}{{.Ext}}",
+            pkg:      "server.go",
+            platform: Platform{"windows", "amd64"},
+            want:     "dist/windows_amd64/server.exe",
+        },
+        {
+            name:     "test1",
+            template: "dist/{{.Version}}/{{.Name}}_{{.OS}}_{{.Arch}}",
+            pkg:      "cmd/gos/main.go",
+            version:  "v1.2.0",
+            platform: Platform{"linux", "arm64"},
+            want:     "dist/v1.2.0/gos_linux_arm64",
+        },
+        {
+            name:     "test2",
+            template: "dist/{{.OS}}_{{.Arch}}v{{.Arm}}/{{.Name}}",
+            pkg:      "server.go",
+            platform: Platform{"linux", "arm"},
+            arm:      "6",
+            want:     "dist/linux_armv6/server",
+        },
+        {
+            name:     "test3",
+            template: "dist/{{.OS}}_{{.Arch}}{{.Arm}}/{{.Name}}",
+            pkg:      "server.go",
+            platform: Platform{"linux", "arm64"},
+            arm:      "6",
+            want:     "dist/linux_arm64/server",
+        },
+    }
+    for _, tt := range tests {
+        tmpl, err := NewOutputTemplate(tt.template)
+        assert.Equal(t, nil, err, tt.name)
+        got, err := ExecuteOutputTemplate(tmpl, NewOutputFields(tt.pkg, tt.version, tt.platform, tt.arm))
+        assert.Equal(t, nil, err, tt.name)
+        assert.Equal(t, filepath.FromSlash(tt.want), got, tt.name)
+    }
+}
+
+func TestExecuteOutputTemplate_UnknownField(t *testing.T) {
+    tmpl, err := NewOutputTemplate("{{.Unknown}}")
+    assert.Equal(t, nil, err)
+    _, err = ExecuteOutputTemplate(tmpl, NewOutputFields("main.go", "", Platform{"linux", "amd64"}, ""))
+    assert.Equal(t, true, err != nil)
+}
+
+func TestIsOutputTemplate(t *testing.T) {
+    assert.Equal(t, true, IsOutputTemplate("dist/{{.OS}}/app"))
+    assert.Equal(t, false, IsOutputTemplate("dist/app"))
+}
+
+func TestParseArch(t *testing.T) {
+    arch, arm, err := parseArch("arm/v6")
+    assert.Equal(t, nil, err)
+    assert.Equal(t, "arm", arch)
+    assert.Equal(t, "6", arm)
+
+    arch, arm, err = parseArch("all")
+    assert.Equal(t, nil, err)
+    assert.Equal(t, "all", arch)
+    assert.Equal(t, "", arm)
+
+    _, _, err = parseArch("arm/v8")
+    assert.Equal(t, ErrInvalidArm, err)
+    _, _, err = parseArch("arm64/v6")
+    assert.Equal(t, ErrInvalidArm, err)
+}
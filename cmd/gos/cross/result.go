@@ -0,0 +1,100 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cross
+
+import (
+    "fmt"
+    "io"
+    "text/tabwriter"
+    "time"
+
+    "github.com/json-iterator/go"
+)
+
+// Define a set of result status
+const (
+    StatusSucceeded = "succeeded"
+    StatusFailed    = "failed"
+)
+
+// Result defines the result of a compilation target
+type Result struct {
+    Target string `json:"target"`
+    Status string `json:"status"`
+    Output string `json:"output"`
+    // Size is the size of the binary in bytes
+    Size int64 `json:"size"`
+    // Duration is the compilation time in nanoseconds
+    Duration time.Duration `json:"duration"`
+    Error    string        `json:"error,omitempty"`
+}
+
+// Failed is used to determine whether the target failed to compile
+func (r *Result) Failed() bool {
+    return r.Status != StatusSucceeded
+}
+
+// Results is a set of compilation results
+type Results []*Result
+
+// Failed is used to count the failed targets
+func (rs Results) Failed() (n int) {
+    for _, r := range rs {
+        if r.Failed() {
+            n++
+        }
+    }
+    return n
+}
+
+// WriteTable is used to print a human-readable summary table
+func (rs Results) WriteTable(w io.Writer) error {
+    tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(tw, "TARGET\tSTATUS\tDURATION\tSIZE\tOUTPUT")
+    for _, r := range rs {
+        size := "-"
+        if !r.Failed() {
+            size = FormatSize(r.Size)
+        }
+        fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+            r.Target, r.Status, r.Duration.Round(time.Millisecond), size, r.Output)
+    }
+    fmt.Fprintf(tw, "\n%d succeeded, %d failed\n", len(rs)-rs.Failed(), rs.Failed())
+    return tw.Flush()
+}
+
+// WriteJSON is used to print the results in json format
+func (rs Results) WriteJSON(w io.Writer) error {
+    if rs == nil {
+        rs = Results{}
+    }
+    encoder := jsoniter.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(rs)
+}
+
+// FormatSize is used to format the byte size into a human-readable string
+func FormatSize(size int64) string {
+    const unit = 1024
+    if size < unit {
+        return fmt.Sprintf("%dB", size)
+    }
+    div, exp := int64(unit), 0
+    for n := size / unit; n >= unit; n /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cross
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+)
+
+var (
+    // ErrInvalidToolchain is returned when the toolchain argument is not os/arch=value
+    ErrInvalidToolchain = errors.New("invalid toolchain, expected os/arch=value")
+    // ErrNoCompiler is returned when the toolchain of a target is configured without a C compiler,
+    // and no cross compiler of the target can be found in $PATH
+    ErrNoCompiler = errors.New("no C compiler is configured or found, specify it by --cc")
+)
+
+// Toolchain defines the C toolchain used to compile cgo for a target platform
+type Toolchain struct {
+    CC      string
+    CXX     string
+    Sysroot string
+}
+
+// Env is used to get the cgo environment variables of the toolchain
+func (t *Toolchain) Env() []string {
+    env := []string{"CC=" + t.CC}
+    if t.CXX != "" {
+        env = append(env, "CXX="+t.CXX)
+    }
+    if t.Sysroot != "" {
+        sysroot := "--sysroot=" + t.Sysroot
+        env = append(env,
+            "CGO_CFLAGS="+joinFlags(os.Getenv("CGO_CFLAGS"), "-O2 -g", sysroot),
+            "CGO_CXXFLAGS="+joinFlags(os.Getenv("CGO_CXXFLAGS"), "-O2 -g", sysroot),
+            "CGO_LDFLAGS="+joinFlags(os.Getenv("CGO_LDFLAGS"), "-O2 -g", sysroot),
+        )
+    }
+    return env
+}
+
+// joinFlags appends flag to the current flags, falling back to the defaults of go
+func joinFlags(current, defaults, flag string) string {
+    if current == "" {
+        current = defaults
+    }
+    return current + " " + flag
+}
+
+// Toolchains maps the target platform such as "linux/arm64" to its toolchain
+type Toolchains map[string]*Toolchain
+
+// Set is used to parse the argument of --cc/--cxx/--sysroot, such as "linux/arm64=aarch64-linux-gnu-gcc"
+func (ts Toolchains) Set(kind string, value string) error {
+    i := strings.Index(value, "=")
+    if i <= 0 || i == len(value)-1 || !strings.Contains(value[:i], "/") {
+        return ErrInvalidToolchain
+    }
+    target, value := value[:i], value[i+1:]
+    toolchain, ok := ts[target]
+    if !ok {
+        toolchain = &Toolchain{}
+        ts[target] = toolchain
+    }
+    switch kind {
+    case "cc":
+        toolchain.CC = value
+    case "cxx":
+        toolchain.CXX = value
+    case "sysroot":
+        toolchain.Sysroot = value
+    }
+    return nil
+}
+
+// Get is used to get the toolchain of the platform, the compilers which
+// are not configured will be detected from $PATH when detect is true.
+// It is nil when the platform has no toolchain, and an error is returned
+// when the sysroot or C++ compiler of the platform is configured without a C compiler
+func (ts Toolchains) Get(p Platform, detect bool) (*Toolchain, error) {
+    toolchain := &Toolchain{}
+    configured, ok := ts[p.String()]
+    if ok {
+        *toolchain = *configured
+    }
+    if toolchain.CC == "" && (detect || ok) {
+        if detected := DetectToolchain(p); detected != nil {
+            toolchain.CC = detected.CC
+            if toolchain.CXX == "" {
+                toolchain.CXX = detected.CXX
+            }
+        }
+    }
+    if toolchain.CC == "" {
+        if ok {
+            return nil, fmt.Errorf("%s: %s", p.String(), ErrNoCompiler)
+        }
+        return nil, nil
+    }
+    return toolchain, nil
+}
+
+// crossCompilers defines the prefixes of common gcc cross compilers,
+// such as aarch64-linux-gnu-gcc and aarch64-linux-gnu-g++
+var crossCompilers = map[string][]string{
+    "linux/386":      {"i686-linux-gnu"},
+    "linux/amd64":    {"x86_64-linux-gnu"},
+    "linux/arm":      {"arm-linux-gnueabihf", "arm-linux-gnueabi"},
+    "linux/arm64":    {"aarch64-linux-gnu"},
+    "linux/ppc64":    {"powerpc64-linux-gnu"},
+    "linux/ppc64le":  {"powerpc64le-linux-gnu"},
+    "linux/s390x":    {"s390x-linux-gnu"},
+    "linux/mips":     {"mips-linux-gnu"},
+    "linux/mipsle":   {"mipsel-linux-gnu"},
+    "linux/mips64":   {"mips64-linux-gnuabi64"},
+    "linux/mips64le": {"mips64el-linux-gnuabi64"},
+    "windows/386":    {"i686-w64-mingw32"},
+    "windows/amd64":  {"x86_64-w64-mingw32"},
+}
+
+// zigTargets defines the zig target triples used by "zig cc"
+var zigTargets = map[string]string{
+    "linux/386":     "x86-linux-gnu",
+    "linux/amd64":   "x86_64-linux-gnu",
+    "linux/arm":     "arm-linux-gnueabihf",
+    "linux/arm64":   "aarch64-linux-gnu",
+    "linux/ppc64le": "powerpc64le-linux-gnu",
+    "linux/s390x":   "s390x-linux-gnu",
+    "windows/386":   "x86-windows-gnu",
+    "windows/amd64": "x86_64-windows-gnu",
+    "darwin/amd64":  "x86_64-macos",
+    "darwin/arm64":  "aarch64-macos",
+}
+
+// DetectToolchain is used to find a cross compiler of the platform in $PATH,
+// gcc cross compilers are preferred, followed by zig.
+// The paths are quoted in CC and CXX, which are split by go like a shell
+func DetectToolchain(p Platform) *Toolchain {
+    target := p.String()
+    for _, prefix := range crossCompilers[target] {
+        cc, err := exec.LookPath(prefix + "-gcc")
+        if err != nil {
+            continue
+        }
+        toolchain := &Toolchain{CC: quoteCommand(cc)}
+        if cxx, err := exec.LookPath(prefix + "-g++"); err == nil {
+            toolchain.CXX = quoteCommand(cxx)
+        }
+        if toolchain.CC != "" {
+            return toolchain
+        }
+    }
+    if triple, ok := zigTargets[target]; ok {
+        if zig, err := exec.LookPath("zig"); err == nil {
+            toolchain := &Toolchain{
+                CC:  quoteCommand(zig, "cc", "-target", triple),
+                CXX: quoteCommand(zig, "c++", "-target", triple),
+            }
+            if toolchain.CC != "" {
+                return toolchain
+            }
+        }
+    }
+    return nil
+}
+
+// quoteCommand is used to join the arguments into the value of CC or CXX,
+// the argument with spaces is quoted in the same way as cmd/internal/quoted of go.
+// It returns empty string when an argument contains both single and double quotes
+func quoteCommand(args ...string) string {
+    quoted := make([]string, 0, len(args))
+    for _, arg := range args {
+        switch {
+        case !strings.ContainsAny(arg, " \t\n\r'\""):
+            quoted = append(quoted, arg)
+        case !strings.Contains(arg, "'"):
+            quoted = append(quoted, "'"+arg+"'")
+        case !strings.Contains(arg, "\""):
+            quoted = append(quoted, "\""+arg+"\"")
+        default:
+            return ""
+        }
+    }
+    return strings.Join(quoted, " ")
+}
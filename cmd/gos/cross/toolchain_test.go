@@ -0,0 +1,101 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cross
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestToolchains_Set(t *testing.T) {
+    ts := Toolchains{}
+    assert.Equal(t, nil, ts.Set("cc", "linux/arm64=zig cc -target aarch64-linux-gnu"))
+    assert.Equal(t, nil, ts.Set("sysroot", "linux/arm64=/opt/sysroot"))
+    assert.Equal(t, &Toolchain{
+        CC:      "zig cc -target aarch64-linux-gnu",
+        Sysroot: "/opt/sysroot",
+    }, ts["linux/arm64"])
+
+    assert.Equal(t, ErrInvalidToolchain, ts.Set("cc", "aarch64-linux-gnu-gcc"))
+    assert.Equal(t, ErrInvalidToolchain, ts.Set("cc", "linux/arm64="))
+}
+
+func TestToolchains_Get(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    for _, name := range []string{"aarch64-linux-gnu-gcc", "aarch64-linux-gnu-g++"} {
+        err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755)
+        assert.Equal(t, nil, err)
+    }
+    path := os.Getenv("PATH")
+    defer os.Setenv("PATH", path)
+    os.Setenv("PATH", dir)
+
+    ts := Toolchains{}
+    arm64 := Platform{"linux", "arm64"}
+    toolchain, err := ts.Get(arm64, false)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, (*Toolchain)(nil), toolchain)
+    toolchain, err = ts.Get(arm64, true)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, &Toolchain{
+        CC:  filepath.Join(dir, "aarch64-linux-gnu-gcc"),
+        CXX: filepath.Join(dir, "aarch64-linux-gnu-g++"),
+    }, toolchain)
+    s390x := Platform{"linux", "s390x"}
+    toolchain, err = ts.Get(s390x, true)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, (*Toolchain)(nil), toolchain)
+
+    // the sysroot is configured without a C compiler which can be found
+    assert.Equal(t, nil, ts.Set("sysroot", "linux/s390x=/opt/s390x"))
+    _, err = ts.Get(s390x, false)
+    assert.EqualError(t, err, "linux/s390x: "+ErrNoCompiler.Error())
+
+    assert.Equal(t, nil, ts.Set("cc", "linux/arm64=clang"))
+    toolchain, err = ts.Get(arm64, true)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, "clang", toolchain.CC)
+}
+
+func TestDetectToolchain_Zig(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    bin := filepath.Join(dir, "Program Files", "zig")
+    assert.Equal(t, nil, os.MkdirAll(bin, os.ModePerm))
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(bin, "zig"), []byte("#!/bin/sh\n"), 0755))
+    path := os.Getenv("PATH")
+    defer os.Setenv("PATH", path)
+    os.Setenv("PATH", bin)
+
+    zig := filepath.Join(bin, "zig")
+    assert.Equal(t, &Toolchain{
+        CC:  "'" + zig + "' cc -target s390x-linux-gnu",
+        CXX: "'" + zig + "' c++ -target s390x-linux-gnu",
+    }, DetectToolchain(Platform{"linux", "s390x"}))
+}
+
+func TestQuoteCommand(t *testing.T) {
+    assert.Equal(t, "/usr/bin/zig cc", quoteCommand("/usr/bin/zig", "cc"))
+    assert.Equal(t, `'/opt/my zig/zig' cc`, quoteCommand("/opt/my zig/zig", "cc"))
+    assert.Equal(t, `"/opt/it's zig/zig" cc`, quoteCommand("/opt/it's zig/zig", "cc"))
+    assert.Equal(t, "", quoteCommand(`/opt/"it's"/zig`))
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cross
+
+import (
+    "bytes"
+    "errors"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "sync"
+
+    "github.com/hashicorp/go-multierror"
+    "github.com/storyicon/gos/pkg/concurrent"
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// Download is used to resolve and download the module graph once,
+// so that the parallel compilations will not race to fetch the same modules
+func (o *Options) Download() error {
+    stderr := &bytes.Buffer{}
+    cmd := util.GetGoBinaryCMD("mod", []string{"download"})
+    cmd.Env = util.GetEnvWithLocalProxy()
+    cmd.Dir = filepath.Dir(o.Package)
+    cmd.Stdout = ioutil.Discard
+    cmd.Stderr = stderr
+
+    if err := cmd.Run(); err != nil {
+        return errors.New(strings.TrimSpace(stderr.String()))
+    }
+    return nil
+}
+
+// WarmUp is used to build the std library for all workhorses with limited concurrency
+func WarmUp(horses []*Workhorse, parallel int) error {
+    var errs error
+    var lock sync.Mutex
+    c := concurrent.New(parallel)
+    for _, horse := range horses {
+        c.Add(1)
+        go func(horse *Workhorse) {
+            defer c.Done()
+            if err := horse.WarmUp(); err != nil {
+                lock.Lock()
+                defer lock.Unlock()
+                errs = multierror.Append(errs, err)
+            }
+        }(horse)
+    }
+    c.Wait()
+    return errs
+}
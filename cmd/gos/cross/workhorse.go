@@ -16,7 +16,10 @@ package cross
 
 import (
     "io/ioutil"
+    "path/filepath"
     "runtime"
+    "text/template"
+    "time"
 
     "bytes"
 
@@ -27,6 +30,7 @@ import (
 
     "os"
 
+    "github.com/storyicon/gos/pkg/stamp"
     "github.com/storyicon/gos/pkg/util"
 )
 
@@ -36,42 +40,124 @@ type Workhorse struct {
     StandardGO []string
     Output     string
     Platform
+    // Arm is the GOARM of the arm target, it is empty when it is not specified
+    Arm string
+
+    // Template is the parsed output template, it is nil when -o is a plain path
+    Template *template.Template
+    // Version is the output of git describe used by the output template
+    Version string
+    // Toolchain is the C toolchain of the cross compilation,
+    // cgo will be enabled when it is not nil
+    Toolchain *Toolchain
+    // Stamp is used to inject version metadata, it can be nil
+    Stamp *stamp.Stamp
 }
 
 // Compile is the highlight
 func (h *Workhorse) Compile() error {
-    env := append(util.GetEnvWithLocalProxy(),
-        "GOOS="+h.OS,
-        "GOARCH="+h.Arch,
-        h.GetCGOEnv(),
-    )
     // In order to avoid duplicate names
     output, err := h.GetRealOutput()
     if err != nil {
         return err
     }
+    return h.compile(output)
+}
 
-    stderr := &bytes.Buffer{}
-    args := append(h.StandardGO, "-o", output, h.Package)
+// compile is used to compile the package into the output
+func (h *Workhorse) compile(output string) error {
+    if dir := filepath.Dir(output); dir != "." {
+        if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+            return err
+        }
+    }
+
+    args := h.getArgs("-o", output, h.Package)
+    if h.Stamp != nil {
+        args = h.Stamp.Apply(args, h.Target())
+    }
 
+    stderr := &bytes.Buffer{}
     cmd := util.GetGoBinaryCMD("build", args)
-    cmd.Env = env
+    cmd.Env = h.getEnv()
     cmd.Stdout = ioutil.Discard
     cmd.Stderr = stderr
 
     if err := cmd.Run(); err != nil {
-        err = fmt.Errorf("%s: %s", h.Platform.String(), stderr.String())
+        err = fmt.Errorf("%s: %s", h.Target(), stderr.String())
         return err
     }
     return nil
 }
 
+// WarmUp is used to build the std library of the target platform in advance
+func (h *Workhorse) WarmUp() error {
+    stderr := &bytes.Buffer{}
+    cmd := util.GetGoBinaryCMD("build", h.getArgs("std"))
+    cmd.Env = h.getEnv()
+    cmd.Stdout = ioutil.Discard
+    cmd.Stderr = stderr
+
+    if err := cmd.Run(); err != nil {
+        return fmt.Errorf("%s: %s", h.Target(), stderr.String())
+    }
+    return nil
+}
+
+// Build is used to compile and collect the result of the compilation
+func (h *Workhorse) Build() *Result {
+    start := time.Now()
+    result := &Result{
+        Target: h.Target(),
+        Status: StatusSucceeded,
+    }
+    output, err := h.GetRealOutput()
+    if err == nil {
+        result.Output = output
+        err = h.compile(output)
+    }
+    result.Duration = time.Since(start)
+    if err != nil {
+        result.Status = StatusFailed
+        result.Error = err.Error()
+        return result
+    }
+    if info, err := os.Stat(result.Output); err == nil {
+        result.Size = info.Size()
+    }
+    return result
+}
+
+func (h *Workhorse) getEnv() []string {
+    cgo := h.GetCGOEnv()
+    env := append(util.GetEnvWithLocalProxy(),
+        "GOOS="+h.OS,
+        "GOARCH="+h.Arch,
+        cgo,
+    )
+    if h.Arm != "" {
+        env = append(env, "GOARM="+h.Arm)
+    }
+    if h.Toolchain != nil && cgo == "CGO_ENABLED=1" {
+        env = append(env, h.Toolchain.Env()...)
+    }
+    return env
+}
+
+// getArgs copies StandardGO since it is shared by all workhorses
+func (h *Workhorse) getArgs(args ...string) []string {
+    r := make([]string, 0, len(h.StandardGO)+len(args))
+    r = append(r, h.StandardGO...)
+    return append(r, args...)
+}
+
 // GetCGOEnv is used to determine whether to enable CGO
 // when there is no corresponding environment variable.
+// CGO is enabled for the native platform and the platforms with a C toolchain.
 func (h *Workhorse) GetCGOEnv() string {
     Cgo := os.Getenv("CGO_ENABLED")
     if Cgo == "" {
-        if runtime.GOOS == h.OS && runtime.GOARCH == h.Arch {
+        if runtime.GOOS == h.OS && runtime.GOARCH == h.Arch || h.Toolchain != nil {
             Cgo = "1"
         } else {
             Cgo = "0"
@@ -80,21 +166,37 @@ func (h *Workhorse) GetCGOEnv() string {
     return fmt.Sprintf("CGO_ENABLED=%s", Cgo)
 }
 
+// Target is used to get the name of the target, such as linux/amd64 and linux/arm/v6
+func (h *Workhorse) Target() string {
+    if h.Arm != "" {
+        return h.Platform.String() + "/v" + h.Arm
+    }
+    return h.Platform.String()
+}
+
 // GetRealOutput is used to generate the real output address
 func (h *Workhorse) GetRealOutput() (string, error) {
+    if h.Template != nil {
+        return ExecuteOutputTemplate(h.Template, NewOutputFields(h.Package, h.Version, h.Platform, h.Arm))
+    }
+
     if h.Output == "" {
         ext := path.Ext(h.Package)
         if ext != ".go" {
             return "", ErrMissingGoFile
         }
-        h.Output = strings.TrimRight(h.Package, ext)
+        h.Output = strings.TrimSuffix(h.Package, ext)
     }
 
-    output := strings.Join([]string{
+    parts := []string{
         h.Output,
         h.OS,
         h.Arch,
-    }, "_")
+    }
+    if h.Arm != "" {
+        parts = append(parts, "v"+h.Arm)
+    }
+    output := strings.Join(parts, "_")
 
     if h.OS == "windows" {
         output += ".exe"
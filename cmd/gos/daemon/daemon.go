@@ -0,0 +1,125 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "time"
+
+    "github.com/spf13/cobra"
+    "github.com/storyicon/gos/pkg/daemon"
+    "github.com/storyicon/gos/pkg/meta"
+    "github.com/storyicon/gos/pkg/proxy"
+)
+
+// CmdDaemon is the command line for running the shared local proxy
+var CmdDaemon = &cobra.Command{
+    Use:   "daemon",
+    Short: "run the local proxy shared by gos processes",
+    Long: `
+Usage:
+    gos daemon [--idle duration] [--go binary]
+    gos daemon status
+    gos daemon stop
+
+    The gos processes which pull packages share one local proxy, one module storage and
+    one fetch for the same module in flight through the gos daemon.
+    It is started in the background by the first gos process that needs it,
+    found by the state file in the user cache folder, and exits after it is idle for a while.
+    A gos process pings the daemon while it runs, so the daemon is not idle during a long build.
+    Set GOS_DAEMON=off to start a local proxy in each gos process instead.
+
+    [--idle duration] exit after no request for the duration, the default is 10m
+    [--go binary] the go binary used to fetch modules, the default is the go binary of gos
+
+    - Run the daemon in the foreground with debug output
+    GOS_DEBUG=1 gos daemon
+
+    - Print the pid and address of the running daemon
+    gos daemon status
+`,
+    Args: cobra.NoArgs,
+}
+
+// CmdStatus is the command line for printing the state of the daemon
+var CmdStatus = &cobra.Command{
+    Use:   "status",
+    Short: "print the state of the running gos daemon",
+    Args:  cobra.NoArgs,
+}
+
+// CmdStop is the command line for stopping the daemon
+var CmdStop = &cobra.Command{
+    Use:   "stop",
+    Short: "stop the running gos daemon",
+    Args:  cobra.NoArgs,
+}
+
+func init() {
+    CmdDaemon.Run = Run
+    CmdStatus.Run = RunStatus
+    CmdStop.Run = RunStop
+    CmdDaemon.Flags().Duration("idle", daemon.DefaultIdle, "exit after no request for the duration")
+    CmdDaemon.Flags().String("go", "", "the go binary used to fetch modules, the default is the go binary of gos")
+    CmdDaemon.AddCommand(CmdStatus, CmdStop)
+    // the daemon uses the go binary of the process which starts it, so that they have the same key
+    daemon.SpawnArgs = func() []string {
+        return []string{CmdDaemon.Name(), "--go", meta.GetGoBinaryPath()}
+    }
+}
+
+// Run is used to execute the daemon command
+func Run(cmd *cobra.Command, args []string) {
+    idle, _ := cmd.Flags().GetDuration("idle")
+    if binary, _ := cmd.Flags().GetString("go"); binary != "" {
+        meta.SetGoBinaryPath(binary)
+    }
+    key := daemon.DefaultKey()
+    log.Printf("gos daemon %d started, state file %s", os.Getpid(), daemon.StatePath(key))
+    if err := daemon.Serve(key, proxy.Default(), idle); err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    log.Printf("gos daemon %d exited", os.Getpid())
+}
+
+// RunStatus is used to execute the status command
+func RunStatus(cmd *cobra.Command, args []string) {
+    state, err := daemon.Find(daemon.DefaultKey())
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    fmt.Printf("pid:     %d\naddr:    %s\nstarted: %s (%s ago)\n",
+        state.PID, state.Addr, state.StartedAt.Format(time.RFC3339), time.Since(state.StartedAt).Round(time.Second))
+}
+
+// RunStop is used to execute the stop command
+func RunStop(cmd *cobra.Command, args []string) {
+    state, err := daemon.Find(daemon.DefaultKey())
+    if err == nil {
+        err = state.Stop()
+    }
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    log.Printf("gos daemon %d is stopping", state.PID)
+}
@@ -0,0 +1,512 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/storyicon/gos/pkg/protoparse"
+    "github.com/storyicon/gos/pkg/vcs"
+
+    "github.com/spf13/cobra"
+)
+
+// CmdBreaking is the command line for detecting breaking changes of proto files
+var CmdBreaking = &cobra.Command{
+    Use:   "breaking --against revision [file]",
+    Short: "detect the wire incompatible changes of proto files",
+    Long: `
+Usage:
+    gos proto breaking --against revision [--exclude pattern] [file]
+
+    [--against revision] the git revision to compare with, such as master, v1.2.0 or HEAD~1
+
+    [file] the proto files to check, the same as gos proto, the default is all/all
+
+    The proto files of the revision are read by git and parsed by gos itself, protoc is not required.
+    The messages, enums and services are matched by their names, the rules are:
+    FILE_NO_DELETE              the proto file is not deleted
+    PACKAGE_NO_CHANGE           the package is not changed
+    MESSAGE_NO_DELETE           the message is not deleted
+    FIELD_NO_DELETE             the field is not deleted unless its number is reserved
+    FIELD_SAME_NUMBER           the number of field is not changed
+    FIELD_SAME_NAME             the field is not renamed, which breaks JSON
+    FIELD_SAME_TYPE             the type of field is not changed to a wire incompatible type,
+                                int32, uint32, int64, uint64 and bool, sint32 and sint64,
+                                fixed32 and sfixed32, fixed64 and sfixed64, string and bytes are compatible
+    FIELD_SAME_CARDINALITY      the field is not changed between repeated and singular
+    FIELD_SAME_ONEOF            the field is not moved into or out of a oneof
+    ENUM_NO_DELETE              the enum is not deleted
+    ENUM_NO_RENAME              the enum is not renamed
+    ENUM_VALUE_NO_DELETE        the enum value is not deleted unless its number is reserved
+    ENUM_VALUE_SAME_NAME        the enum value is not renamed
+    SERVICE_NO_DELETE           the service is not deleted
+    RPC_NO_DELETE               the rpc is not deleted
+    RPC_SAME_REQUEST_TYPE       the request type of rpc is not changed
+    RPC_SAME_RESPONSE_TYPE      the response type of rpc is not changed
+    RPC_SAME_STREAMING          the rpc is not changed between streaming and unary
+
+    The issues are printed as file:line:column: message (RULE), gos exits with code 1 if there is any issue
+
+    - Compare all proto files with the master branch
+    gos proto breaking --against master
+
+    - Compare the proto files under ./api with the last commit
+    gos proto breaking --against HEAD~1 ./api/...
+`,
+    Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+    CmdBreaking.Run = RunBreaking
+    CmdBreaking.Flags().String("against", "", "the git revision to compare with, such as master or HEAD~1")
+}
+
+// ErrMissingAgainst is returned when --against is not specified
+var ErrMissingAgainst = errors.New("--against is required, such as --against master")
+
+// declarations indexes the messages, enums and services of a file by their names,
+// the nested names are joined by dots, such as Outer.Inner
+type declarations struct {
+    pkg      string
+    messages map[string]*protoparse.Message
+    enums    map[string]*protoparse.Enum
+    services map[string]*protoparse.Service
+    // names records the names of messages, enums and services in the order of declaration
+    messageNames, enumNames, serviceNames []string
+}
+
+func collectDeclarations(file *protoparse.File) *declarations {
+    d := &declarations{
+        pkg:      file.Package,
+        messages: map[string]*protoparse.Message{},
+        enums:    map[string]*protoparse.Enum{},
+        services: map[string]*protoparse.Service{},
+    }
+    d.addMessages("", file.Messages)
+    d.addEnums("", file.Enums)
+    for _, service := range file.Services {
+        d.services[service.Name] = service
+        d.serviceNames = append(d.serviceNames, service.Name)
+    }
+    return d
+}
+
+func (d *declarations) addMessages(scope string, messages []*protoparse.Message) {
+    for _, message := range messages {
+        name := scope + message.Name
+        d.messages[name] = message
+        d.messageNames = append(d.messageNames, name)
+        d.addMessages(name+".", message.Messages)
+        d.addEnums(name+".", message.Enums)
+    }
+}
+
+func (d *declarations) addEnums(scope string, enums []*protoparse.Enum) {
+    for _, enum := range enums {
+        d.enums[scope+enum.Name] = enum
+        d.enumNames = append(d.enumNames, scope+enum.Name)
+    }
+}
+
+// position is used to get the position of the nearest declaration containing name,
+// it is used to report the deleted declarations
+func (d *declarations) position(file *protoparse.File, name string) protoparse.Position {
+    for i := strings.LastIndex(name, "."); i != -1; i = strings.LastIndex(name, ".") {
+        name = name[:i]
+        if message, ok := d.messages[name]; ok {
+            return message.Position
+        }
+    }
+    return file.Position
+}
+
+// parentDeleted is used to determine whether the message containing name is deleted,
+// the nested declarations of a deleted message are not reported again
+func (d *declarations) parentDeleted(name string, previous *declarations) bool {
+    i := strings.LastIndex(name, ".")
+    if i == -1 {
+        return false
+    }
+    parent := name[:i]
+    _, existed := previous.messages[parent]
+    _, exists := d.messages[parent]
+    return existed && !exists
+}
+
+// Breaking is used to find the wire incompatible changes from previous to current
+func Breaking(previous *protoparse.File, current *protoparse.File) []*Issue {
+    var issues []*Issue
+    if previous.Package != current.Package {
+        issues = append(issues, newIssue(current, current.Position, "PACKAGE_NO_CHANGE", "package changed from %q to %q", previous.Package, current.Package))
+    }
+    old, cur := collectDeclarations(previous), collectDeclarations(current)
+    for _, name := range old.messageNames {
+        message, ok := cur.messages[name]
+        if !ok {
+            if cur.parentDeleted(name, old) {
+                continue
+            }
+            issues = append(issues, newIssue(current, cur.position(current, name), "MESSAGE_NO_DELETE", "message %q was deleted", name))
+            continue
+        }
+        issues = append(issues, compareFields(old, cur, current, name, old.messages[name], message)...)
+    }
+    for _, name := range old.enumNames {
+        enum, ok := cur.enums[name]
+        if !ok {
+            if renamed := findRenamedEnum(name, old, cur); renamed != "" {
+                issues = append(issues, newIssue(current, cur.enums[renamed].Position, "ENUM_NO_RENAME", "enum %q was renamed to %q", name, renamed))
+            } else if !cur.parentDeleted(name, old) {
+                issues = append(issues, newIssue(current, cur.position(current, name), "ENUM_NO_DELETE", "enum %q was deleted", name))
+            }
+            continue
+        }
+        issues = append(issues, compareEnumValues(current, name, old.enums[name], enum)...)
+    }
+    for _, name := range old.serviceNames {
+        service, ok := cur.services[name]
+        if !ok {
+            issues = append(issues, newIssue(current, current.Position, "SERVICE_NO_DELETE", "service %q was deleted", name))
+            continue
+        }
+        issues = append(issues, compareMethods(old, cur, current, name, old.services[name], service)...)
+    }
+    SortIssues(issues)
+    return issues
+}
+
+// scalarTypes defines the scalar types of proto, which are not resolved as message or enum names
+var scalarTypes = []string{
+    "double", "float", "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+    "fixed32", "fixed64", "sfixed32", "sfixed64", "bool", "string", "bytes",
+}
+
+// wireCompatibleTypes defines the groups of scalar types which have the same wire encoding,
+// changing a field between the types of a group does not break the binary format
+var wireCompatibleTypes = [][]string{
+    {"int32", "uint32", "int64", "uint64", "bool"},
+    {"sint32", "sint64"},
+    {"fixed32", "sfixed32"},
+    {"fixed64", "sfixed64"},
+    {"string", "bytes"},
+}
+
+// compatibleType is used to determine whether the field type can be changed from previous to current
+func compatibleType(previous string, current string) bool {
+    if previous == current {
+        return true
+    }
+    for _, group := range wireCompatibleTypes {
+        if containsString(group, previous) && containsString(group, current) {
+            return true
+        }
+    }
+    return false
+}
+
+// qualify is used to resolve the type referenced in scope into its fully qualified name without the leading dot,
+// such as Address in message user.v1.User into user.v1.User.Address. Like protoc, the first part of the name
+// is searched from the innermost scope to the outermost in the declarations and packages of the file,
+// the names which are not declared in the file are assumed to be in the package of the file
+func (d *declarations) qualify(scope string, typ string) string {
+    if strings.HasPrefix(typ, "map<") && strings.HasSuffix(typ, ">") {
+        parts := strings.SplitN(typ[len("map<"):len(typ)-1], ",", 2)
+        if len(parts) == 2 {
+            return fmt.Sprintf("map<%s, %s>", strings.TrimSpace(parts[0]), d.qualify(scope, strings.TrimSpace(parts[1])))
+        }
+    }
+    if strings.HasPrefix(typ, ".") {
+        return typ[1:]
+    }
+    if containsString(scalarTypes, typ) {
+        return typ
+    }
+    first := typ
+    if i := strings.Index(typ, "."); i != -1 {
+        first = typ[:i]
+    }
+    for {
+        if d.declares(join(scope, first)) {
+            return join(scope, typ)
+        }
+        if scope == "" {
+            break
+        }
+        if i := strings.LastIndex(scope, "."); i != -1 {
+            scope = scope[:i]
+        } else {
+            scope = ""
+        }
+    }
+    if first == typ {
+        return join(d.pkg, typ)
+    }
+    return typ
+}
+
+// declares is used to determine whether the fully qualified name is a package, message or enum of the file
+func (d *declarations) declares(name string) bool {
+    if name == d.pkg || strings.HasPrefix(d.pkg, name+".") {
+        return true
+    }
+    local := name
+    if d.pkg != "" {
+        if !strings.HasPrefix(name, d.pkg+".") {
+            return false
+        }
+        local = name[len(d.pkg)+1:]
+    }
+    _, isMessage := d.messages[local]
+    _, isEnum := d.enums[local]
+    return isMessage || isEnum
+}
+
+// join is used to join the scope and name with a dot
+func join(scope string, name string) string {
+    if scope == "" {
+        return name
+    }
+    return scope + "." + name
+}
+
+func compareFields(previousDecls *declarations, decls *declarations, file *protoparse.File, name string, previous *protoparse.Message, current *protoparse.Message) []*Issue {
+    var issues []*Issue
+    byNumber, byName := map[int]*protoparse.Field{}, map[string]*protoparse.Field{}
+    for _, field := range current.Fields {
+        byNumber[field.Number] = field
+        byName[field.Name] = field
+    }
+    for _, old := range previous.Fields {
+        field, ok := byNumber[old.Number]
+        if !ok {
+            if moved, ok := byName[old.Name]; ok {
+                issues = append(issues, newIssue(file, moved.Position, "FIELD_SAME_NUMBER", "field %q of message %q changed number from %d to %d", old.Name, name, old.Number, moved.Number))
+            } else if !current.Reserved.HasNumber(old.Number) {
+                issues = append(issues, newIssue(file, current.Position, "FIELD_NO_DELETE", "field %d %q was deleted from message %q without reserving its number", old.Number, old.Name, name))
+            }
+            continue
+        }
+        if field.Name != old.Name {
+            issues = append(issues, newIssue(file, field.Position, "FIELD_SAME_NAME", "field %d of message %q was renamed from %q to %q", old.Number, name, old.Name, field.Name))
+        }
+        scope := join(decls.pkg, name)
+        if !compatibleType(previousDecls.qualify(join(previousDecls.pkg, name), old.Type), decls.qualify(scope, field.Type)) {
+            issues = append(issues, newIssue(file, field.Position, "FIELD_SAME_TYPE", "field %d %q of message %q changed type from %q to %q", old.Number, field.Name, name, old.Type, field.Type))
+        }
+        if (field.Label == "repeated") != (old.Label == "repeated") {
+            issues = append(issues, newIssue(file, field.Position, "FIELD_SAME_CARDINALITY", "field %d %q of message %q changed between repeated and singular", old.Number, field.Name, name))
+        }
+        if field.Oneof != old.Oneof {
+            issues = append(issues, newIssue(file, field.Position, "FIELD_SAME_ONEOF", "field %d %q of message %q changed oneof from %q to %q", old.Number, field.Name, name, old.Oneof, field.Oneof))
+        }
+    }
+    return issues
+}
+
+// findRenamedEnum is used to find the enum which is added in the same scope with the same values
+func findRenamedEnum(name string, previous *declarations, current *declarations) string {
+    scope := ""
+    if i := strings.LastIndex(name, "."); i != -1 {
+        scope = name[:i+1]
+    }
+    values := enumValuesKey(previous.enums[name])
+    for _, candidate := range current.enumNames {
+        if _, ok := previous.enums[candidate]; ok {
+            continue
+        }
+        rest := strings.TrimPrefix(candidate, scope)
+        if !strings.HasPrefix(candidate, scope) || strings.Contains(rest, ".") {
+            continue
+        }
+        if enumValuesKey(current.enums[candidate]) == values {
+            return candidate
+        }
+    }
+    return ""
+}
+
+func enumValuesKey(enum *protoparse.Enum) string {
+    var values []string
+    for _, value := range enum.Values {
+        values = append(values, fmt.Sprintf("%s=%d", value.Name, value.Number))
+    }
+    sort.Strings(values)
+    return strings.Join(values, ",")
+}
+
+func compareEnumValues(file *protoparse.File, name string, previous *protoparse.Enum, current *protoparse.Enum) []*Issue {
+    var issues []*Issue
+    names := map[int][]string{}
+    positions := map[int]protoparse.Position{}
+    for _, value := range current.Values {
+        if _, ok := positions[value.Number]; !ok {
+            positions[value.Number] = value.Position
+        }
+        names[value.Number] = append(names[value.Number], value.Name)
+    }
+    for _, old := range previous.Values {
+        aliases, ok := names[old.Number]
+        if !ok {
+            if !current.Reserved.HasNumber(old.Number) {
+                issues = append(issues, newIssue(file, current.Position, "ENUM_VALUE_NO_DELETE", "enum value %d %q was deleted from enum %q without reserving its number", old.Number, old.Name, name))
+            }
+            continue
+        }
+        if !containsString(aliases, old.Name) {
+            issues = append(issues, newIssue(file, positions[old.Number], "ENUM_VALUE_SAME_NAME", "enum value %d of enum %q was renamed from %q to %q", old.Number, name, old.Name, aliases[0]))
+        }
+    }
+    return issues
+}
+
+func compareMethods(previousDecls *declarations, decls *declarations, file *protoparse.File, name string, previous *protoparse.Service, current *protoparse.Service) []*Issue {
+    var issues []*Issue
+    methods := map[string]*protoparse.Method{}
+    for _, method := range current.Methods {
+        methods[method.Name] = method
+    }
+    for _, old := range previous.Methods {
+        method, ok := methods[old.Name]
+        if !ok {
+            issues = append(issues, newIssue(file, current.Position, "RPC_NO_DELETE", "rpc %q was deleted from service %q", old.Name, name))
+            continue
+        }
+        if decls.qualify(decls.pkg, method.Input) != previousDecls.qualify(previousDecls.pkg, old.Input) {
+            issues = append(issues, newIssue(file, method.Position, "RPC_SAME_REQUEST_TYPE", "rpc %q of service %q changed request type from %q to %q", old.Name, name, old.Input, method.Input))
+        }
+        if decls.qualify(decls.pkg, method.Output) != previousDecls.qualify(previousDecls.pkg, old.Output) {
+            issues = append(issues, newIssue(file, method.Position, "RPC_SAME_RESPONSE_TYPE", "rpc %q of service %q changed response type from %q to %q", old.Name, name, old.Output, method.Output))
+        }
+        if method.ClientStreaming != old.ClientStreaming || method.ServerStreaming != old.ServerStreaming {
+            issues = append(issues, newIssue(file, method.Position, "RPC_SAME_STREAMING", "rpc %q of service %q changed streaming", old.Name, name))
+        }
+    }
+    return issues
+}
+
+func containsString(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+// MatchTarget is used to determine whether the slash separated path relative to the current folder
+// is specified by the argument of gos proto, which is a proto file or a wildcard
+func MatchTarget(target string, rel string) bool {
+    switch {
+    case target == "all":
+        return !strings.Contains(rel, "/")
+    case target == "all/all" || target == "...":
+        return true
+    case strings.HasSuffix(target, "/..."):
+        dir := path.Clean(filepath.ToSlash(strings.TrimSuffix(target, "/...")))
+        return dir == "." || rel == dir || strings.HasPrefix(rel, dir+"/")
+    default:
+        return path.Clean(filepath.ToSlash(target)) == rel
+    }
+}
+
+// RunBreaking is used to execute the breaking command
+func RunBreaking(cmd *cobra.Command, args []string) {
+    against, _ := cmd.Flags().GetString("against")
+    if against == "" {
+        log.Println(ErrMissingAgainst)
+        os.Exit(1)
+        return
+    }
+    filter, err := getFilter(cmd)
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    target := getTarget(args)
+    files, err := GetFinder(target, filter)()
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    exists := map[string]bool{}
+    for _, file := range relativePaths(files) {
+        exists[filepath.ToSlash(file)] = true
+    }
+
+    previous, err := vcs.ListFiles(".", against)
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+
+    var issues []*Issue
+    var failed bool
+    var checked int
+    for _, name := range previous {
+        if path.Ext(name) != ".proto" || !MatchTarget(target, name) || filter.ExcludedTree(filepath.FromSlash(name)) {
+            continue
+        }
+        checked++
+        if !exists[name] {
+            issues = append(issues, &Issue{File: name, Line: 1, Column: 1, Rule: "FILE_NO_DELETE", Message: "file was deleted"})
+            continue
+        }
+        result, err := compareRevision(against, name)
+        if err != nil {
+            log.Println(err)
+            failed = true
+            continue
+        }
+        issues = append(issues, result...)
+    }
+    for _, issue := range issues {
+        fmt.Println(issue)
+    }
+    if failed || len(issues) != 0 {
+        log.Printf("%d breaking changes are found in %d files against %s", len(issues), checked, against)
+        os.Exit(1)
+        return
+    }
+    log.Printf("%d files are checked against %s, no breaking change is found :)", checked, against)
+}
+
+// compareRevision is used to compare the proto file with its content at the revision
+func compareRevision(revision string, name string) ([]*Issue, error) {
+    content, err := vcs.ShowFile(".", revision, name)
+    if err != nil {
+        return nil, err
+    }
+    previous, err := protoparse.Parse(name+"@"+revision, content)
+    if err != nil {
+        return nil, err
+    }
+    current, err := protoparse.ParseFile(filepath.FromSlash(name))
+    if err != nil {
+        return nil, err
+    }
+    current.Name = name
+    return Breaking(previous, current), nil
+}
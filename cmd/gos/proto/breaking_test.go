@@ -0,0 +1,155 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "testing"
+
+    "github.com/storyicon/gos/pkg/protoparse"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestBreaking(t *testing.T) {
+    previous, err := protoparse.Parse("user.proto", `syntax = "proto3";
+package user.v1;
+message User {
+    string name = 1;
+    int32 age = 2;
+    string email = 3;
+    repeated string tags = 4;
+    string phone = 5;
+    string nickname = 6;
+    string remark = 9;
+    Status status = 7;
+    message Address { string city = 1; }
+}
+message Deleted { message Nested {} }
+enum Status { STATUS_UNKNOWN = 0; STATUS_OK = 1; STATUS_BANNED = 2; }
+enum Kind { KIND_UNKNOWN = 0; }
+service UserService {
+    rpc GetUser(User) returns (User);
+    rpc Watch(User) returns (stream User);
+    rpc Delete(User) returns (User);
+}
+`)
+    assert.Equal(t, nil, err)
+    current, err := protoparse.Parse("user.proto", `syntax = "proto3";
+package user.v1;
+message User {
+    reserved 5;
+    string full_name = 1;
+    string age = 2;
+    string tags = 4;
+    string email = 8;
+    oneof contact { string nickname = 6; }
+    .user.v1.Status status = 7;
+    message Address { string city = 1; }
+}
+enum Status { STATUS_UNKNOWN = 0; STATUS_ACTIVE = 1; }
+enum UserKind { KIND_UNKNOWN = 0; }
+service UserService {
+    rpc GetUser(User) returns (User.Address);
+    rpc Watch(User) returns (User);
+}
+`)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{
+        `user.proto:1:1: message "Deleted" was deleted (MESSAGE_NO_DELETE)`,
+        `user.proto:3:1: field 9 "remark" was deleted from message "User" without reserving its number (FIELD_NO_DELETE)`,
+        `user.proto:5:5: field 1 of message "User" was renamed from "name" to "full_name" (FIELD_SAME_NAME)`,
+        `user.proto:6:5: field 2 "age" of message "User" changed type from "int32" to "string" (FIELD_SAME_TYPE)`,
+        `user.proto:7:5: field 4 "tags" of message "User" changed between repeated and singular (FIELD_SAME_CARDINALITY)`,
+        `user.proto:8:5: field "email" of message "User" changed number from 3 to 8 (FIELD_SAME_NUMBER)`,
+        `user.proto:9:21: field 6 "nickname" of message "User" changed oneof from "" to "contact" (FIELD_SAME_ONEOF)`,
+        `user.proto:13:1: enum value 2 "STATUS_BANNED" was deleted from enum "Status" without reserving its number (ENUM_VALUE_NO_DELETE)`,
+        `user.proto:13:35: enum value 1 of enum "Status" was renamed from "STATUS_OK" to "STATUS_ACTIVE" (ENUM_VALUE_SAME_NAME)`,
+        `user.proto:14:1: enum "Kind" was renamed to "UserKind" (ENUM_NO_RENAME)`,
+        `user.proto:15:1: rpc "Delete" was deleted from service "UserService" (RPC_NO_DELETE)`,
+        `user.proto:16:5: rpc "GetUser" of service "UserService" changed response type from "User" to "User.Address" (RPC_SAME_RESPONSE_TYPE)`,
+        `user.proto:17:5: rpc "Watch" of service "UserService" changed streaming (RPC_SAME_STREAMING)`,
+    }, getIssues(Breaking(previous, current)))
+}
+
+func TestBreaking_FieldType(t *testing.T) {
+    previous, err := protoparse.Parse("user.proto", `syntax = "proto3";
+package user.v1;
+message User {
+    int32 a = 1;
+    sint32 b = 2;
+    fixed32 c = 3;
+    fixed64 d = 4;
+    string e = 5;
+    Address f = 6;
+    Status g = 7;
+    map<string, Address> h = 8;
+    google.protobuf.Timestamp i = 9;
+    int32 j = 10;
+    sint32 k = 11;
+    Address l = 12;
+    message Address { string city = 1; }
+}
+enum Status { STATUS_UNKNOWN = 0; }
+service UserService {
+    rpc GetUser(User) returns (User);
+}
+`)
+    assert.Equal(t, nil, err)
+    current, err := protoparse.Parse("user.proto", `syntax = "proto3";
+package user.v1;
+message User {
+    uint64 a = 1;
+    sint64 b = 2;
+    sfixed32 c = 3;
+    sfixed64 d = 4;
+    bytes e = 5;
+    .user.v1.User.Address f = 6;
+    v1.Status g = 7;
+    map<string, User.Address> h = 8;
+    .google.protobuf.Timestamp i = 9;
+    sint32 j = 10;
+    fixed32 k = 11;
+    Status l = 12;
+    message Address { string city = 1; }
+}
+enum Status { STATUS_UNKNOWN = 0; }
+service UserService {
+    rpc GetUser(.user.v1.User) returns (user.v1.User);
+}
+`)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{
+        `user.proto:13:5: field 10 "j" of message "User" changed type from "int32" to "sint32" (FIELD_SAME_TYPE)`,
+        `user.proto:14:5: field 11 "k" of message "User" changed type from "sint32" to "fixed32" (FIELD_SAME_TYPE)`,
+        `user.proto:15:5: field 12 "l" of message "User" changed type from "Address" to "Status" (FIELD_SAME_TYPE)`,
+    }, getIssues(Breaking(previous, current)))
+}
+
+func TestMatchTarget(t *testing.T) {
+    tests := []struct {
+        target string
+        rel    string
+        want   bool
+    }{
+        {"all", "a.proto", true},
+        {"all", "api/a.proto", false},
+        {"all/all", "api/a.proto", true},
+        {"./api/...", "api/v1/a.proto", true},
+        {"./api/...", "apis/a.proto", false},
+        {"api/a.proto", "api/a.proto", true},
+    }
+    for _, tt := range tests {
+        assert.Equal(t, tt.want, MatchTarget(tt.target, tt.rel), tt.target+" "+tt.rel)
+    }
+}
@@ -0,0 +1,288 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "bytes"
+    "errors"
+    "io/ioutil"
+    "log"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// ErrOutsideWorkDir is returned when the proto file to check is outside the current folder
+var ErrOutsideWorkDir = errors.New("the proto file is outside the current folder")
+
+// ErrOutputOutsideWorkDir is returned when the output folder of plugin to check is outside the current folder,
+// such as an absolute "out"
+var ErrOutputOutsideWorkDir = errors.New("the output folder is outside the current folder, which can not be checked")
+
+var generatedPattern = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`)
+
+// CheckResult defines the generated files which are not up to date
+type CheckResult struct {
+    // Stale is the files whose content is different from the generated ones
+    Stale []string
+    // Missing is the generated files which do not exist
+    Missing []string
+    // Orphaned is the generated files which are no longer generated from any proto file
+    Orphaned []string
+}
+
+// OK is used to determine whether all generated files are up to date
+func (r *CheckResult) OK() bool {
+    return len(r.Stale) == 0 && len(r.Missing) == 0 && len(r.Orphaned) == 0
+}
+
+// Check is used to generate the proto files into a temporary folder
+// and compare the generated files with the files in the current folder
+func (g *Generator) Check(files []string) (*CheckResult, error) {
+    tmp, err := ioutil.TempDir("", "gos-proto-check")
+    if err != nil {
+        return nil, err
+    }
+    defer os.RemoveAll(tmp)
+
+    checker := *g
+    checker.OutputRoot = tmp
+    checker.State = nil
+    if err := checker.GenerateFiles(files); err != nil {
+        return nil, err
+    }
+    result, err := CompareDir(tmp, ".")
+    if err != nil {
+        return nil, err
+    }
+    if !g.coversFolders(files) {
+        // the other proto files in the folders may generate files into the same output folders
+        result.Orphaned = nil
+    }
+    if g.State != nil {
+        result.Orphaned = append(result.Orphaned, g.findRecordedOrphans(files, tmp)...)
+    }
+    result.Orphaned = unique(result.Orphaned)
+    return result, nil
+}
+
+// coversFolders is used to determine whether the files include all proto files in their folders,
+// except the files excluded by the filter
+func (g *Generator) coversFolders(files []string) bool {
+    checked := map[string]bool{}
+    for _, file := range files {
+        if abs, err := filepath.Abs(file); err == nil {
+            checked[abs] = true
+        }
+    }
+    for file := range checked {
+        matches, err := filepath.Glob(filepath.Join(filepath.Dir(file), "*.proto"))
+        if err != nil {
+            return false
+        }
+        for _, match := range matches {
+            if !checked[match] && !g.Filter.Excluded(match, false) {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+// findRecordedOrphans is used to find the files generated last time which are not generated now,
+// the groups whose proto files exist but are not checked are skipped
+func (g *Generator) findRecordedOrphans(files []string, generated string) []string {
+    checked := map[string]bool{}
+    for _, file := range files {
+        if abs, err := filepath.Abs(file); err == nil {
+            checked[filepath.ToSlash(abs)] = true
+        }
+    }
+    var orphaned []string
+    for key, group := range g.State.Groups {
+        skipped := false
+        for _, proto := range strings.Split(key, ",") {
+            if _, err := os.Stat(filepath.FromSlash(proto)); err == nil && !checked[proto] {
+                skipped = true
+                break
+            }
+        }
+        if skipped {
+            continue
+        }
+        for path := range group.Outputs {
+            file, err := relativePath(filepath.FromSlash(path))
+            if err != nil {
+                continue
+            }
+            if _, err := os.Stat(filepath.Join(generated, file)); !os.IsNotExist(err) {
+                continue
+            }
+            if _, err := os.Stat(file); err == nil {
+                orphaned = append(orphaned, file)
+            }
+        }
+    }
+    return orphaned
+}
+
+// relativePath is used to get the path relative to the current folder, the path must be inside it
+func relativePath(path string) (string, error) {
+    abs, err := filepath.Abs(path)
+    if err != nil {
+        return "", err
+    }
+    wd, err := os.Getwd()
+    if err != nil {
+        return "", err
+    }
+    rel, err := filepath.Rel(wd, abs)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", ErrOutsideWorkDir
+    }
+    return rel, nil
+}
+
+func unique(files []string) []string {
+    var r []string
+    seen := map[string]bool{}
+    for _, file := range files {
+        if !seen[file] {
+            seen[file] = true
+            r = append(r, file)
+        }
+    }
+    sort.Strings(r)
+    return r
+}
+
+// CompareDir is used to compare all files in generated with the files of the same path in dir,
+// the files with the "Code generated ... DO NOT EDIT." header in the same folders of dir
+// which are not in generated are orphaned
+func CompareDir(generated string, dir string) (*CheckResult, error) {
+    result := &CheckResult{}
+    err := filepath.Walk(generated, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if info.IsDir() {
+            rel, err := filepath.Rel(generated, path)
+            if err != nil {
+                return err
+            }
+            orphaned, err := findOrphaned(path, filepath.Join(dir, rel))
+            for _, name := range orphaned {
+                result.Orphaned = append(result.Orphaned, filepath.Join(rel, name))
+            }
+            return err
+        }
+        rel, err := filepath.Rel(generated, path)
+        if err != nil {
+            return err
+        }
+        expected, err := ioutil.ReadFile(path)
+        if err != nil {
+            return err
+        }
+        actual, err := ioutil.ReadFile(filepath.Join(dir, rel))
+        if os.IsNotExist(err) {
+            result.Missing = append(result.Missing, rel)
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if !bytes.Equal(expected, actual) {
+            result.Stale = append(result.Stale, rel)
+        }
+        return nil
+    })
+    sort.Strings(result.Stale)
+    sort.Strings(result.Missing)
+    sort.Strings(result.Orphaned)
+    return result, err
+}
+
+// findOrphaned is used to find the generated files in dir which are not in the generated folder,
+// the folders in which nothing is generated are skipped
+func findOrphaned(generated string, dir string) ([]string, error) {
+    files, err := ioutil.ReadDir(generated)
+    if err != nil {
+        return nil, err
+    }
+    hasFile := false
+    for _, file := range files {
+        hasFile = hasFile || !file.IsDir()
+    }
+    if !hasFile {
+        return nil, nil
+    }
+    elements, err := ioutil.ReadDir(dir)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var orphaned []string
+    for _, ele := range elements {
+        if ele.IsDir() {
+            continue
+        }
+        if _, err := os.Stat(filepath.Join(generated, ele.Name())); !os.IsNotExist(err) {
+            continue
+        }
+        data, err := ioutil.ReadFile(filepath.Join(dir, ele.Name()))
+        if err != nil {
+            return nil, err
+        }
+        if generatedPattern.Match(data) {
+            orphaned = append(orphaned, ele.Name())
+        }
+    }
+    return orphaned, nil
+}
+
+// runCheck is used to execute the check mode and get the exit code
+func runCheck(g *Generator, find func() ([]string, error)) int {
+    files, err := find()
+    if err != nil {
+        log.Println(err)
+        return 1
+    }
+    result, err := g.Check(files)
+    if err != nil {
+        log.Println(err)
+        return 1
+    }
+    for _, file := range result.Stale {
+        log.Printf("* %s: stale", file)
+    }
+    for _, file := range result.Missing {
+        log.Printf("* %s: missing", file)
+    }
+    for _, file := range result.Orphaned {
+        log.Printf("* %s: stale, it is not generated from any proto file", file)
+    }
+    if !result.OK() {
+        log.Printf("%d stale and %d missing files, please run gos proto to regenerate them and remove the files which are not generated",
+            len(result.Stale)+len(result.Orphaned), len(result.Missing))
+        return 1
+    }
+    log.Println("All generated files are up to date :)")
+    return 0
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestCompareDir(t *testing.T) {
+    generated, err := ioutil.TempDir("", "gos-proto-generated")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(generated)
+    dir, err := ioutil.TempDir("", "gos-proto-dir")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    write := func(root string, name string, content string) {
+        path := filepath.Join(root, name)
+        assert.Equal(t, nil, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+        assert.Equal(t, nil, ioutil.WriteFile(path, []byte(content), 0644))
+    }
+    write(generated, "a.pb.go", "a")
+    write(generated, filepath.Join("api", "b.pb.go"), "b")
+    write(generated, filepath.Join("api", "c.pb.go"), "c")
+    write(dir, "a.pb.go", "a")
+    write(dir, filepath.Join("api", "b.pb.go"), "old")
+    write(dir, "unrelated.go", "x")
+    write(dir, filepath.Join("api", "removed.pb.go"), "// Code generated by protoc-gen-go. DO NOT EDIT.\n")
+    write(dir, filepath.Join("other", "other.pb.go"), "// Code generated by protoc-gen-go. DO NOT EDIT.\n")
+
+    result, err := CompareDir(generated, dir)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, false, result.OK())
+    assert.Equal(t, []string{filepath.Join("api", "b.pb.go")}, result.Stale)
+    assert.Equal(t, []string{filepath.Join("api", "c.pb.go")}, result.Missing)
+    assert.Equal(t, []string{filepath.Join("api", "removed.pb.go")}, result.Orphaned)
+}
+
+func TestGenerator_GetOutputDir(t *testing.T) {
+    g := &Generator{OutputRoot: filepath.Join(os.TempDir(), "gos-proto-check")}
+    group := &Group{Dir: "api", Files: []string{"user.proto"}}
+
+    out, err := g.getOutputDir(group, &Plugin{Name: "go", Out: "gen"})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, filepath.Join(g.OutputRoot, "api", "gen"), out)
+
+    abs, err := filepath.Abs("gen")
+    assert.Equal(t, nil, err)
+    _, err = g.getOutputDir(group, &Plugin{Name: "go", Out: abs})
+    assert.Equal(t, true, err != nil && strings.Contains(err.Error(), ErrOutputOutsideWorkDir.Error()))
+    _, err = g.getOutputDir(group, &Plugin{Name: "go", Out: filepath.Join("..", "..", "gen")})
+    assert.Equal(t, true, err != nil && strings.Contains(err.Error(), ErrOutputOutsideWorkDir.Error()))
+}
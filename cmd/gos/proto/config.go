@@ -0,0 +1,152 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "strings"
+
+    "github.com/json-iterator/go"
+)
+
+// DefaultConfigFile is the project config file of gos proto,
+// it will be loaded from the current folder if it exists
+const DefaultConfigFile = "gos-proto.json"
+
+// ErrInvalidPlugin is returned when the --plugin argument cannot be parsed
+var ErrInvalidPlugin = errors.New("invalid plugin, expected name[:option,option...]")
+
+// Config defines the structure of gos proto configuration
+type Config struct {
+    // Plugins is the set of protoc plugins, such as go, go-grpc and grpc-gateway
+    Plugins []*Plugin `json:"plugins"`
+    // Includes is the additional proto import paths
+    Includes []string `json:"includes,omitempty"`
+    // Excludes is the patterns of files and folders skipped when searching proto files
+    Excludes []string `json:"excludes,omitempty"`
+    // DisableModules disables the import paths of the module dependencies in go.mod
+    DisableModules bool `json:"disable_modules,omitempty"`
+    // Protoc is the pinned version of protoc, protoc in $PATH is used when it is nil
+    Protoc *Protoc `json:"protoc,omitempty"`
+}
+
+// Plugin defines a protoc plugin and its options
+type Plugin struct {
+    // Name is the name of plugin, protoc-gen-{Name} will be used
+    Name string `json:"name"`
+    // Out is the output folder relative to the proto file, the default is "."
+    Out string `json:"out,omitempty"`
+    // Options is the plugin options, such as paths=source_relative and module=...
+    Options []string `json:"options,omitempty"`
+    // Path is the location of plugin binary, the default is protoc-gen-{Name} in $PATH
+    Path string `json:"path,omitempty"`
+    // Module is the pinned plugin such as google.golang.org/protobuf/cmd/protoc-gen-go@v1.28.1,
+    // it will be installed by go install into the gos managed folder
+    Module string `json:"module,omitempty"`
+}
+
+// pluginDefaultOptions defines the options required by some plugins
+var pluginDefaultOptions = map[string][]string{
+    "validate": {"lang=go"},
+}
+
+// DefaultConfig is used when there is no config file and no --plugin,
+// the messages and services are generated by protoc-gen-go and protoc-gen-go-grpc
+func DefaultConfig() *Config {
+    return &Config{
+        Plugins: []*Plugin{
+            {Name: "go", Options: []string{"paths=source_relative"}},
+            {Name: "go-grpc", Options: []string{"paths=source_relative"}},
+        },
+    }
+}
+
+// DeprecatedPlugins is used to get the plugins using the grpc mode of golang/protobuf,
+// which is not supported by protoc-gen-go of google.golang.org/protobuf
+func (c *Config) DeprecatedPlugins() []*Plugin {
+    var plugins []*Plugin
+    for _, plugin := range c.Plugins {
+        for _, option := range plugin.Options {
+            if plugin.Name == "go" && strings.HasPrefix(option, "plugins=") {
+                plugins = append(plugins, plugin)
+                break
+            }
+        }
+    }
+    return plugins
+}
+
+// LoadConfig is used to load the config file, when path is empty,
+// DefaultConfigFile is used if it exists, otherwise DefaultConfig is returned
+func LoadConfig(path string) (*Config, error) {
+    if path == "" {
+        if _, err := os.Stat(DefaultConfigFile); err != nil {
+            return DefaultConfig(), nil
+        }
+        path = DefaultConfigFile
+    }
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    config := &Config{}
+    if err := jsoniter.Unmarshal(data, config); err != nil {
+        return nil, fmt.Errorf("%s: %s", path, err)
+    }
+    if len(config.Plugins) == 0 {
+        config.Plugins = DefaultConfig().Plugins
+    }
+    return config, nil
+}
+
+// ParsePlugin is used to parse the --plugin argument,
+// such as "go-grpc:paths=source_relative,require_unimplemented_servers=false"
+func ParsePlugin(value string) (*Plugin, error) {
+    name, options := value, ""
+    if i := strings.Index(value, ":"); i != -1 {
+        name, options = value[:i], value[i+1:]
+    }
+    if name == "" {
+        return nil, ErrInvalidPlugin
+    }
+    plugin := &Plugin{Name: name}
+    if options != "" {
+        plugin.Options = strings.Split(options, ",")
+    }
+    return plugin, nil
+}
+
+// Args is used to get the protoc arguments of the plugin
+func (p *Plugin) Args() []string {
+    var args []string
+    if p.Path != "" {
+        args = append(args, fmt.Sprintf("--plugin=protoc-gen-%s=%s", p.Name, p.Path))
+    }
+    out := p.Out
+    if out == "" {
+        out = "."
+    }
+    options := p.Options
+    if len(options) == 0 {
+        options = pluginDefaultOptions[p.Name]
+    }
+    if len(options) != 0 {
+        out = strings.Join(options, ",") + ":" + out
+    }
+    return append(args, fmt.Sprintf("--%s_out=%s", p.Name, out))
+}
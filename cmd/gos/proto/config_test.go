@@ -0,0 +1,79 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestParsePlugin(t *testing.T) {
+    plugin, err := ParsePlugin("go-grpc:paths=source_relative,require_unimplemented_servers=false")
+    assert.Equal(t, nil, err)
+    assert.Equal(t, &Plugin{
+        Name:    "go-grpc",
+        Options: []string{"paths=source_relative", "require_unimplemented_servers=false"},
+    }, plugin)
+
+    plugin, err = ParsePlugin("openapiv2")
+    assert.Equal(t, nil, err)
+    assert.Equal(t, &Plugin{Name: "openapiv2"}, plugin)
+
+    _, err = ParsePlugin(":paths=source_relative")
+    assert.Equal(t, ErrInvalidPlugin, err)
+}
+
+func TestConfig_DeprecatedPlugins(t *testing.T) {
+    assert.Equal(t, 0, len(DefaultConfig().DeprecatedPlugins()))
+    deprecated := &Plugin{Name: "go", Options: []string{"plugins=grpc", "paths=source_relative"}}
+    config := &Config{Plugins: []*Plugin{deprecated, {Name: "go-grpc"}}}
+    assert.Equal(t, []*Plugin{deprecated}, config.DeprecatedPlugins())
+}
+
+func TestPlugin_Args(t *testing.T) {
+    tests := []struct {
+        name   string
+        plugin *Plugin
+        want   []string
+    }{
+        {
+            name:   "test0",
+            plugin: DefaultConfig().Plugins[1],
+            want:   []string{"--go-grpc_out=paths=source_relative:."},
+        },
+        {
+            name:   "test1",
+            plugin: &Plugin{Name: "validate"},
+            want:   []string{"--validate_out=lang=go:."},
+        },
+        {
+            name: "test2",
+            plugin: &Plugin{
+                Name:    "grpc-gateway",
+                Out:     "gateway",
+                Options: []string{"paths=source_relative"},
+                Path:    "/usr/local/bin/protoc-gen-grpc-gateway",
+            },
+            want: []string{
+                "--plugin=protoc-gen-grpc-gateway=/usr/local/bin/protoc-gen-grpc-gateway",
+                "--grpc-gateway_out=paths=source_relative:gateway",
+            },
+        },
+    }
+    for _, tt := range tests {
+        assert.Equal(t, tt.want, tt.plugin.Args(), tt.name)
+    }
+}
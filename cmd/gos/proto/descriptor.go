@@ -0,0 +1,104 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+
+    "github.com/golang/protobuf/proto"
+    "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// WriteDescriptorSet is used to compile the proto files into a FileDescriptorSet including their imports.
+// protoc is invoked once per group in the folder of the group, the same as generation,
+// so that the imports are resolved in the same way, and the sets of the groups are merged into one
+func (g *Generator) WriteDescriptorSet(files []string, out string) error {
+    groups, err := GroupFiles(files)
+    if err != nil {
+        return err
+    }
+    dir, err := ioutil.TempDir("", "gos-descriptor")
+    if err != nil {
+        return err
+    }
+    defer os.RemoveAll(dir)
+
+    var sets []*descriptor.FileDescriptorSet
+    for i, group := range groups {
+        set, err := g.compileDescriptorSet(group, filepath.Join(dir, fmt.Sprintf("%d.pb", i)))
+        if err != nil {
+            return err
+        }
+        sets = append(sets, set)
+    }
+    data, err := proto.Marshal(MergeDescriptorSets(sets...))
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(out), os.ModePerm); err != nil {
+        return err
+    }
+    return ioutil.WriteFile(out, data, 0644)
+}
+
+// compileDescriptorSet is used to compile a group of proto files into the FileDescriptorSet file out
+func (g *Generator) compileDescriptorSet(group *Group, out string) (*descriptor.FileDescriptorSet, error) {
+    fd := exec.Command(ProtocBinaryPath, g.descriptorSetArgs(group, out)...)
+    stderr := &bytes.Buffer{}
+    fd.Stdout = stderr
+    fd.Stderr = stderr
+    fd.Dir = group.Dir
+    if err := fd.Run(); err != nil {
+        return nil, fmt.Errorf("%s: %s", group, stderr.String())
+    }
+    data, err := ioutil.ReadFile(out)
+    if err != nil {
+        return nil, err
+    }
+    set := &descriptor.FileDescriptorSet{}
+    if err := proto.Unmarshal(data, set); err != nil {
+        return nil, fmt.Errorf("%s: %s", group, err)
+    }
+    return set, nil
+}
+
+// descriptorSetArgs is used to get the arguments of protoc to write the FileDescriptorSet of the group,
+// which is executed in the folder of the group like GenerateGroup
+func (g *Generator) descriptorSetArgs(group *Group, out string) []string {
+    args := append(g.protoPathArgs(), "--descriptor_set_out="+out, "--include_imports")
+    return append(args, group.Files...)
+}
+
+// MergeDescriptorSets is used to merge the FileDescriptorSets into one,
+// a file imported by several groups is kept once, in the order it first appears
+func MergeDescriptorSets(sets ...*descriptor.FileDescriptorSet) *descriptor.FileDescriptorSet {
+    merged := &descriptor.FileDescriptorSet{}
+    seen := map[string]bool{}
+    for _, set := range sets {
+        for _, file := range set.GetFile() {
+            if seen[file.GetName()] {
+                continue
+            }
+            seen[file.GetName()] = true
+            merged.File = append(merged.File, file)
+        }
+    }
+    return merged
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/golang/protobuf/proto"
+    "github.com/golang/protobuf/protoc-gen-go/descriptor"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestDescriptorSetArgs(t *testing.T) {
+    g := &Generator{ProtoPaths: []string{"/include"}}
+    args := g.descriptorSetArgs(&Group{Dir: "api/v1", Files: []string{"a.proto", "b.proto"}}, "/tmp/out.pb")
+    assert.Equal(t, []string{
+        "--proto_path=.", "--proto_path=/include", "--descriptor_set_out=/tmp/out.pb", "--include_imports",
+        "a.proto", "b.proto",
+    }, args)
+}
+
+func TestMergeDescriptorSets(t *testing.T) {
+    set := func(names ...string) *descriptor.FileDescriptorSet {
+        s := &descriptor.FileDescriptorSet{}
+        for _, name := range names {
+            s.File = append(s.File, &descriptor.FileDescriptorProto{Name: proto.String(name)})
+        }
+        return s
+    }
+    var names []string
+    for _, file := range MergeDescriptorSets(set("b.proto", "a.proto"), set("b.proto", "c.proto")).File {
+        names = append(names, file.GetName())
+    }
+    assert.Equal(t, []string{"b.proto", "a.proto", "c.proto"}, names)
+}
+
+func TestWriteDescriptorSet_SiblingImport(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    // a.proto imports its sibling by the bare name "b.proto", which is only resolvable
+    // when protoc runs in the folder of the group, the fake protoc fails otherwise
+    api := filepath.Join(dir, "api")
+    assert.Equal(t, nil, os.MkdirAll(api, os.ModePerm))
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(api, "a.proto"), []byte("syntax = \"proto3\";\nimport \"b.proto\";\n"), 0644))
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(api, "b.proto"), []byte("syntax = \"proto3\";\n"), 0644))
+
+    data, err := proto.Marshal(&descriptor.FileDescriptorSet{File: []*descriptor.FileDescriptorProto{
+        {Name: proto.String("b.proto")},
+        {Name: proto.String("a.proto"), Dependency: []string{"b.proto"}},
+    }})
+    assert.Equal(t, nil, err)
+    fixture := filepath.Join(dir, "fixture.pb")
+    assert.Equal(t, nil, ioutil.WriteFile(fixture, data, 0644))
+
+    protoc := filepath.Join(dir, "protoc")
+    script := "#!/bin/sh\ntest -f b.proto || { echo 'b.proto: File not found.' >&2; exit 1; }\n" +
+        "for arg; do case $arg in --descriptor_set_out=*) cp " + fixture + " ${arg#*=};; esac; done\n"
+    assert.Equal(t, nil, ioutil.WriteFile(protoc, []byte(script), 0755))
+    defer func(path string) {
+        ProtocBinaryPath = path
+    }(ProtocBinaryPath)
+    ProtocBinaryPath = protoc
+
+    out := filepath.Join(dir, "out", "api.pb")
+    g := &Generator{Config: &Config{}}
+    assert.Equal(t, nil, g.WriteDescriptorSet([]string{filepath.Join(api, "a.proto")}, out))
+
+    data, err = ioutil.ReadFile(out)
+    assert.Equal(t, nil, err)
+    set := &descriptor.FileDescriptorSet{}
+    assert.Equal(t, nil, proto.Unmarshal(data, set))
+    assert.Equal(t, 2, len(set.File))
+    assert.Equal(t, "a.proto", set.File[1].GetName())
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "bufio"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// DefaultExcludes is the folders skipped when searching proto files,
+// they usually contain vendored or unrelated proto files
+var DefaultExcludes = []string{"vendor/", "node_modules/", ".git/"}
+
+// IgnoreFiles is the files in the current folder whose patterns are excluded,
+// they are in the syntax of .gitignore
+var IgnoreFiles = []string{".gosignore", ".gitignore"}
+
+// Pattern is an exclude pattern in the syntax of .gitignore
+type Pattern struct {
+    glob     string
+    negate   bool
+    dirOnly  bool
+    anchored bool
+}
+
+// ParsePattern is used to parse a line of .gitignore, nil is returned for blank lines and comments
+func ParsePattern(line string) *Pattern {
+    line = strings.TrimSpace(line)
+    if line == "" || strings.HasPrefix(line, "#") {
+        return nil
+    }
+    p := &Pattern{}
+    if strings.HasPrefix(line, "!") {
+        p.negate = true
+        line = line[1:]
+    }
+    if strings.HasSuffix(line, "/") {
+        p.dirOnly = true
+        line = strings.TrimRight(line, "/")
+    }
+    if strings.Contains(line, "/") {
+        p.anchored = true
+        line = strings.TrimPrefix(line, "/")
+    }
+    if line == "" {
+        return nil
+    }
+    p.glob = line
+    return p
+}
+
+// Match is used to determine whether the slash separated path relative to the filter root matches the pattern
+func (p *Pattern) Match(rel string, isDir bool) bool {
+    if p.dirOnly && !isDir {
+        return false
+    }
+    if !p.anchored {
+        return matchSegments([]string{p.glob}, []string{path.Base(rel)})
+    }
+    return matchSegments(strings.Split(p.glob, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches the path segments with the pattern segments, "**" matches any number of segments
+func matchSegments(pattern []string, name []string) bool {
+    for len(pattern) != 0 {
+        if pattern[0] == "**" {
+            for i := 0; i <= len(name); i++ {
+                if matchSegments(pattern[1:], name[i:]) {
+                    return true
+                }
+            }
+            return false
+        }
+        if len(name) == 0 {
+            return false
+        }
+        if ok, _ := path.Match(pattern[0], name[0]); !ok {
+            return false
+        }
+        pattern, name = pattern[1:], name[1:]
+    }
+    return len(name) == 0
+}
+
+// Filter is used to exclude the files and folders when searching proto files
+type Filter struct {
+    // Root is the folder which the patterns are relative to
+    Root     string
+    Patterns []*Pattern
+}
+
+// NewFilter is used to initialize a Filter in root with the exclude patterns,
+// the default excludes and the patterns of IgnoreFiles are added unless noIgnore is true
+func NewFilter(root string, excludes []string, noIgnore bool) (*Filter, error) {
+    root, err := filepath.Abs(root)
+    if err != nil {
+        return nil, err
+    }
+    f := &Filter{Root: root}
+    if !noIgnore {
+        f.Add(DefaultExcludes...)
+        for _, name := range IgnoreFiles {
+            if err := f.Load(filepath.Join(root, name)); err != nil {
+                return nil, err
+            }
+        }
+    }
+    f.Add(excludes...)
+    return f, nil
+}
+
+// Add is used to add the exclude patterns
+func (f *Filter) Add(lines ...string) {
+    for _, line := range lines {
+        if p := ParsePattern(line); p != nil {
+            f.Patterns = append(f.Patterns, p)
+        }
+    }
+}
+
+// Load is used to add the patterns of the ignore file, it is ignored if the file does not exist
+func (f *Filter) Load(path string) error {
+    file, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        f.Add(scanner.Text())
+    }
+    return scanner.Err()
+}
+
+// Excluded is used to determine whether the file or folder is excluded,
+// the last matching pattern decides, a nil Filter excludes nothing
+func (f *Filter) Excluded(name string, isDir bool) bool {
+    if f == nil {
+        return false
+    }
+    abs, err := filepath.Abs(name)
+    if err != nil {
+        return false
+    }
+    rel, err := filepath.Rel(f.Root, abs)
+    if err != nil || rel == "." {
+        return false
+    }
+    rel = filepath.ToSlash(rel)
+    excluded := false
+    for _, p := range f.Patterns {
+        if p.Match(rel, isDir) {
+            excluded = !p.negate
+        }
+    }
+    return excluded
+}
+
+// ExcludedTree is used to determine whether the file or any of its parent folders is excluded,
+// it is used for the files which are not found by walking the folders
+func (f *Filter) ExcludedTree(name string) bool {
+    if f == nil {
+        return false
+    }
+    abs, err := filepath.Abs(name)
+    if err != nil {
+        return false
+    }
+    rel, err := filepath.Rel(f.Root, abs)
+    if err != nil {
+        return f.Excluded(abs, false)
+    }
+    dir := f.Root
+    parts := strings.Split(rel, string(filepath.Separator))
+    for _, part := range parts[:len(parts)-1] {
+        dir = filepath.Join(dir, part)
+        if part != ".." && f.Excluded(dir, true) {
+            return true
+        }
+    }
+    return f.Excluded(abs, false)
+}
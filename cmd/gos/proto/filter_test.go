@@ -0,0 +1,96 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestPattern_Match(t *testing.T) {
+    tests := []struct {
+        pattern string
+        rel     string
+        isDir   bool
+        want    bool
+    }{
+        {"vendor/", "vendor", true, true},
+        {"vendor/", "api/vendor", true, true},
+        {"vendor/", "vendor", false, false},
+        {"*.pb.proto", "api/a.pb.proto", false, true},
+        {"/third_party", "third_party", true, true},
+        {"/third_party", "api/third_party", true, false},
+        {"api/*/internal.proto", "api/v1/internal.proto", false, true},
+        {"api/*/internal.proto", "api/v1/x/internal.proto", false, false},
+        {"api/**/internal.proto", "api/v1/x/internal.proto", false, true},
+        {"api/**/internal.proto", "api/internal.proto", false, true},
+        {"**/googleapis", "third_party/googleapis", true, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.pattern+" "+tt.rel, func(t *testing.T) {
+            assert.Equal(t, tt.want, ParsePattern(tt.pattern).Match(tt.rel, tt.isDir))
+        })
+    }
+    assert.Equal(t, (*Pattern)(nil), ParsePattern("# comment"))
+    assert.Equal(t, (*Pattern)(nil), ParsePattern("  "))
+}
+
+func TestFindIter(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    files := []string{
+        "a.proto",
+        "a.go",
+        filepath.Join("api", "b.proto"),
+        filepath.Join("api", "internal.proto"),
+        filepath.Join("vendor", "c.proto"),
+        filepath.Join("third_party", "googleapis", "d.proto"),
+        filepath.Join("third_party", "e.proto"),
+    }
+    for _, file := range files {
+        path := filepath.Join(dir, file)
+        assert.Equal(t, nil, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+        assert.Equal(t, nil, ioutil.WriteFile(path, nil, 0644))
+    }
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(dir, ".gosignore"), []byte("# vendored\nthird_party/\n!third_party/e.proto\n"), 0644))
+
+    filter, err := NewFilter(dir, []string{"internal.proto"}, false)
+    assert.Equal(t, nil, err)
+    found, err := FindIter(dir, filter)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{
+        filepath.Join(dir, "a.proto"),
+        filepath.Join(dir, "api", "b.proto"),
+    }, found)
+
+    found, err = FindIter(filepath.Join(dir, "api"), nil)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{
+        filepath.Join(dir, "api", "b.proto"),
+        filepath.Join(dir, "api", "internal.proto"),
+    }, found)
+
+    filter, err = NewFilter(dir, nil, true)
+    assert.Equal(t, nil, err)
+    found, err = FindIter(dir, filter)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, len(files)-1, len(found))
+}
@@ -0,0 +1,246 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/storyicon/gos/pkg/protoparse"
+
+    "github.com/json-iterator/go"
+    "github.com/spf13/cobra"
+)
+
+// CmdGraph is the command line for printing the import graph of proto files
+var CmdGraph = &cobra.Command{
+    Use:   "graph [file]",
+    Short: "print the import graph of proto files",
+    Long: `
+Usage:
+    gos proto graph [--format text|dot|json] [-I dir] [file]
+
+    [file] the proto files in the graph, the same as gos proto, the default is all/all
+
+    [--format text|dot|json] the output format, the default is text.
+    text lists the imports (->) and the importers (<-) of each file,
+    dot can be rendered by graphviz, json is for other tools
+
+    The imports are resolved in the folder of proto file and the -I / "includes" import paths,
+    the files which are imported but not specified by [file] are marked as external.
+    The services of each file are listed, so that the services affected by a change can be found
+    by following the importers
+
+    - Print the import graph of all proto files
+    gos proto graph
+
+    - Render the import graph of ./api into an image
+    gos proto graph --format dot ./api/... | dot -Tsvg > api.svg
+`,
+    Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+    CmdGraph.Run = RunGraph
+    CmdGraph.Flags().String("format", "text", "the output format, text, dot or json")
+    CmdGraph.Flags().StringArrayP("include", "I", nil, "the additional proto import path, can be specified multiple times")
+}
+
+// ErrInvalidFormat is returned when the format of graph is not supported
+var ErrInvalidFormat = errors.New("invalid format, expected text, dot or json")
+
+// Node is a proto file in the import graph
+type Node struct {
+    // Name is the path relative to the current folder, or the import path if it can not be resolved
+    Name     string   `json:"name"`
+    Package  string   `json:"package,omitempty"`
+    Services []string `json:"services,omitempty"`
+    // Imports and ImportedBy are the names of the imported and importing nodes
+    Imports    []string `json:"imports,omitempty"`
+    ImportedBy []string `json:"imported_by,omitempty"`
+    // External means the file is imported but not specified
+    External bool `json:"external,omitempty"`
+}
+
+// Graph is the import graph of proto files
+type Graph struct {
+    Files []*Node `json:"files"`
+}
+
+// BuildGraph is used to parse the proto files and resolve their imports
+func (g *Generator) BuildGraph(files []string) (*Graph, error) {
+    nodes := map[string]*Node{}
+    getNode := func(name string) *Node {
+        node, ok := nodes[name]
+        if !ok {
+            node = &Node{Name: name, External: true}
+            nodes[name] = node
+        }
+        return node
+    }
+
+    for _, path := range relativePaths(files) {
+        file, err := protoparse.ParseFile(path)
+        if err != nil {
+            return nil, err
+        }
+        node := getNode(filepath.ToSlash(path))
+        node.External = false
+        node.Package = file.Package
+        for _, service := range file.Services {
+            node.Services = append(node.Services, service.Name)
+        }
+        for _, imp := range file.Imports {
+            name := imp.Path
+            if resolved := g.ResolveImport(filepath.Dir(path), imp.Path); resolved != "" {
+                name = filepath.ToSlash(relativePaths([]string{absPath(resolved)})[0])
+            }
+            node.Imports = append(node.Imports, name)
+            imported := getNode(name)
+            imported.ImportedBy = append(imported.ImportedBy, node.Name)
+        }
+    }
+
+    graph := &Graph{}
+    for _, node := range nodes {
+        sort.Strings(node.ImportedBy)
+        graph.Files = append(graph.Files, node)
+    }
+    sort.Slice(graph.Files, func(i, j int) bool {
+        return graph.Files[i].Name < graph.Files[j].Name
+    })
+    return graph, nil
+}
+
+func absPath(path string) string {
+    if abs, err := filepath.Abs(path); err == nil {
+        return abs
+    }
+    return path
+}
+
+// Write is used to write the graph in the format
+func (g *Graph) Write(w io.Writer, format string) error {
+    switch format {
+    case "text":
+        return g.WriteText(w)
+    case "dot":
+        return g.WriteDOT(w)
+    case "json":
+        encoder := jsoniter.NewEncoder(w)
+        encoder.SetIndent("", "  ")
+        return encoder.Encode(g)
+    }
+    return ErrInvalidFormat
+}
+
+// WriteText is used to write the imports and importers of each file
+func (g *Graph) WriteText(w io.Writer) error {
+    for _, node := range g.Files {
+        var attrs []string
+        if node.External {
+            attrs = append(attrs, "external")
+        }
+        if node.Package != "" {
+            attrs = append(attrs, "package "+node.Package)
+        }
+        if len(node.Services) != 0 {
+            attrs = append(attrs, "services "+strings.Join(node.Services, ", "))
+        }
+        line := node.Name
+        if len(attrs) != 0 {
+            line += " (" + strings.Join(attrs, "; ") + ")"
+        }
+        if _, err := fmt.Fprintln(w, line); err != nil {
+            return err
+        }
+        for _, name := range node.Imports {
+            fmt.Fprintf(w, "    -> %s\n", name)
+        }
+        for _, name := range node.ImportedBy {
+            fmt.Fprintf(w, "    <- %s\n", name)
+        }
+    }
+    return nil
+}
+
+// WriteDOT is used to write the graph in the DOT language of graphviz
+func (g *Graph) WriteDOT(w io.Writer) error {
+    if _, err := fmt.Fprintln(w, "digraph protos {\n    rankdir=LR;\n    node [shape=box];"); err != nil {
+        return err
+    }
+    for _, node := range g.Files {
+        label := node.Name
+        if len(node.Services) != 0 {
+            label += "\n" + strings.Join(node.Services, "\n")
+        }
+        style := ""
+        if node.External {
+            style = ", style=dashed"
+        }
+        fmt.Fprintf(w, "    %q [label=%q%s];\n", node.Name, label, style)
+    }
+    for _, node := range g.Files {
+        for _, name := range node.Imports {
+            fmt.Fprintf(w, "    %q -> %q;\n", node.Name, name)
+        }
+    }
+    _, err := fmt.Fprintln(w, "}")
+    return err
+}
+
+// RunGraph is used to execute the graph command
+func RunGraph(cmd *cobra.Command, args []string) {
+    format, _ := cmd.Flags().GetString("format")
+    config, err := getConfig(cmd)
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    filter, err := newFilter(cmd, config)
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    files, err := GetFinder(getTarget(args), filter)()
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+
+    // only the local import paths are used, the graph does not need the module dependencies
+    g := &Generator{Config: config}
+    for _, dir := range config.Includes {
+        g.ProtoPaths = append(g.ProtoPaths, absPath(dir))
+    }
+    graph, err := g.BuildGraph(files)
+    if err == nil {
+        err = graph.Write(os.Stdout, format)
+    }
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+}
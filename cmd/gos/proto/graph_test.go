@@ -0,0 +1,81 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestBuildGraph(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    wd, err := os.Getwd()
+    assert.Equal(t, nil, err)
+    assert.Equal(t, nil, os.Chdir(dir))
+    defer os.Chdir(wd)
+
+    files := map[string]string{
+        "common.proto":                "syntax = \"proto3\";\npackage common;\nmessage Money {}\n",
+        "api/a.proto":                 "syntax = \"proto3\";\npackage api;\nimport \"../common.proto\";\nimport \"google/protobuf/empty.proto\";\nservice A {}\n",
+        "api/b.proto":                 "syntax = \"proto3\";\npackage api;\nimport \"common/common.proto\";\nservice B {}\nservice C {}\n",
+        "include/common/common.proto": "syntax = \"proto3\";\n",
+    }
+    for name, content := range files {
+        path := filepath.FromSlash(name)
+        assert.Equal(t, nil, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+        assert.Equal(t, nil, ioutil.WriteFile(path, []byte(content), 0644))
+    }
+
+    g := &Generator{Config: DefaultConfig(), ProtoPaths: []string{filepath.Join(dir, "include")}}
+    graph, err := g.BuildGraph([]string{"common.proto", filepath.Join("api", "a.proto"), filepath.Join("api", "b.proto")})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*Node{
+        {Name: "api/a.proto", Package: "api", Services: []string{"A"}, Imports: []string{"common.proto", "google/protobuf/empty.proto"}},
+        {Name: "api/b.proto", Package: "api", Services: []string{"B", "C"}, Imports: []string{"include/common/common.proto"}},
+        {Name: "common.proto", Package: "common", ImportedBy: []string{"api/a.proto"}},
+        {Name: "google/protobuf/empty.proto", ImportedBy: []string{"api/a.proto"}, External: true},
+        {Name: "include/common/common.proto", ImportedBy: []string{"api/b.proto"}, External: true},
+    }, graph.Files)
+
+    buf := &bytes.Buffer{}
+    assert.Equal(t, nil, graph.Write(buf, "text"))
+    assert.Equal(t, `api/a.proto (package api; services A)
+    -> common.proto
+    -> google/protobuf/empty.proto
+api/b.proto (package api; services B, C)
+    -> include/common/common.proto
+common.proto (package common)
+    <- api/a.proto
+google/protobuf/empty.proto (external)
+    <- api/a.proto
+include/common/common.proto (external)
+    <- api/b.proto
+`, buf.String())
+
+    buf.Reset()
+    assert.Equal(t, nil, graph.Write(buf, "dot"))
+    assert.Contains(t, buf.String(), "    \"api/b.proto\" [label=\"api/b.proto\\nB\\nC\"];\n")
+    assert.Contains(t, buf.String(), "    \"google/protobuf/empty.proto\" [label=\"google/protobuf/empty.proto\", style=dashed];\n")
+    assert.Contains(t, buf.String(), "    \"api/a.proto\" -> \"common.proto\";\n")
+
+    assert.Equal(t, ErrInvalidFormat, graph.Write(buf, "svg"))
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var goPackagePattern = regexp.MustCompile(`(?m)^\s*option\s+go_package\s*=\s*"([^"]*)"\s*;`)
+
+// Group is a set of proto files in the same folder with the same go_package,
+// which are compiled by one protoc invocation
+type Group struct {
+    // Dir is the folder of proto files, protoc is executed in it
+    Dir string
+    // GoPackage is the go_package option of proto files, it can be empty
+    GoPackage string
+    // Files is the names of proto files in Dir
+    Files []string
+}
+
+func (g *Group) String() string {
+    var paths []string
+    for _, file := range g.Files {
+        paths = append(paths, filepath.Join(g.Dir, file))
+    }
+    return strings.Join(paths, ", ")
+}
+
+// GroupFiles is used to group proto files by folder and go_package,
+// the groups and their files are sorted
+func GroupFiles(files []string) ([]*Group, error) {
+    var groups []*Group
+    index := map[string]*Group{}
+    for _, file := range files {
+        goPackage, err := ReadGoPackage(file)
+        if err != nil {
+            return nil, err
+        }
+        dir, name := filepath.Split(file)
+        key := dir + "\x00" + goPackage
+        group, ok := index[key]
+        if !ok {
+            group = &Group{
+                Dir:       dir,
+                GoPackage: goPackage,
+            }
+            index[key] = group
+            groups = append(groups, group)
+        }
+        group.Files = append(group.Files, name)
+    }
+
+    for _, group := range groups {
+        sort.Strings(group.Files)
+    }
+    sort.Slice(groups, func(i, j int) bool {
+        if groups[i].Dir != groups[j].Dir {
+            return groups[i].Dir < groups[j].Dir
+        }
+        return groups[i].GoPackage < groups[j].GoPackage
+    })
+    return groups, nil
+}
+
+// ReadGoPackage is used to read the go_package option of the proto file
+func ReadGoPackage(file string) (string, error) {
+    data, err := ioutil.ReadFile(file)
+    if err != nil {
+        return "", err
+    }
+    return ParseGoPackage(data), nil
+}
+
+// ParseGoPackage is used to parse the go_package option from the content of proto file
+func ParseGoPackage(data []byte) string {
+    match := goPackagePattern.FindSubmatch(data)
+    if match == nil {
+        return ""
+    }
+    return string(match[1])
+}
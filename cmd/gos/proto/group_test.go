@@ -0,0 +1,52 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestGroupFiles(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    api := filepath.Join(dir, "api")
+    assert.Equal(t, nil, os.MkdirAll(api, os.ModePerm))
+    files := map[string]string{
+        filepath.Join(api, "user.proto"):   "syntax = \"proto3\";\noption go_package = \"example.com/api\";\n",
+        filepath.Join(api, "order.proto"):  "syntax = \"proto3\";\n  option go_package=\"example.com/api\" ;\n",
+        filepath.Join(api, "admin.proto"):  "syntax = \"proto3\";\noption go_package = \"example.com/api/admin\";\n",
+        filepath.Join(dir, "common.proto"): "syntax = \"proto3\";\n",
+    }
+    var paths []string
+    for path, content := range files {
+        assert.Equal(t, nil, ioutil.WriteFile(path, []byte(content), 0644))
+        paths = append(paths, path)
+    }
+
+    groups, err := GroupFiles(paths)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []*Group{
+        {Dir: dir + string(filepath.Separator), Files: []string{"common.proto"}},
+        {Dir: api + string(filepath.Separator), GoPackage: "example.com/api", Files: []string{"order.proto", "user.proto"}},
+        {Dir: api + string(filepath.Separator), GoPackage: "example.com/api/admin", Files: []string{"admin.proto"}},
+    }, groups)
+}
@@ -0,0 +1,309 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/json-iterator/go"
+    "github.com/storyicon/gos/pkg/proxy/module"
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// thirdPartyDirs defines the folders in modules which usually contain vendored protos,
+// such as third_party/googleapis of grpc-gateway
+var thirdPartyDirs = []string{
+    filepath.Join("third_party", "googleapis"),
+    filepath.Join("third_party", "protobuf"),
+}
+
+// GoPathSrc is the address of $GOPATH/src, it is added to the proto paths by ResolveIncludes
+// for the projects which still clone the imported protos into $GOPATH instead of using go modules
+var GoPathSrc = filepath.Join(os.Getenv("GOPATH"), "src")
+
+// ResolveIncludes is used to get the local --proto_path arguments:
+// the configured folders, $GOPATH/src and the well-known types of the protoc installation,
+// the module dependencies are added on demand by ResolveModuleIncludes
+func (c *Config) ResolveIncludes() ([]string, error) {
+    var includes []string
+    for _, dir := range c.Includes {
+        abs, err := filepath.Abs(dir)
+        if err != nil {
+            return nil, err
+        }
+        includes = append(includes, abs)
+    }
+
+    if os.Getenv("GOPATH") != "" && isDir(GoPathSrc) {
+        includes = append(includes, GoPathSrc)
+    }
+
+    if dir := FindWellKnownTypes(); dir != "" {
+        includes = append(includes, dir)
+    }
+    return includes, nil
+}
+
+// ResolveModuleIncludes is used to add the folders of the module dependencies in go.mod
+// which own the imports of the proto files that can not be found in the proto paths.
+// The modules are only downloaded when there is such an import, and they are cached by go.mod and go.sum
+func (g *Generator) ResolveModuleIncludes(files []string) error {
+    if g.Config == nil || g.DisableModules {
+        return nil
+    }
+    var modules []*module.List
+    for {
+        unresolved := g.UnresolvedImports(files)
+        if len(unresolved) == 0 {
+            return nil
+        }
+        if modules == nil {
+            root := FindModuleRoot(".")
+            if root == "" {
+                return nil
+            }
+            var err error
+            if modules, err = LoadModules(root); err != nil {
+                return err
+            }
+        }
+        // the files of the added modules may import the files of other modules
+        if !g.addModuleIncludes(modules, unresolved) {
+            return nil
+        }
+    }
+}
+
+// addModuleIncludes is used to add the include paths of the modules owning the imports,
+// it returns false if nothing is added
+func (g *Generator) addModuleIncludes(modules []*module.List, imports []string) bool {
+    var added bool
+    for _, name := range imports {
+        include := ModuleInclude(modules, name)
+        if include == "" || containsString(g.ModulePaths, include) {
+            continue
+        }
+        g.ModulePaths = append(g.ModulePaths, include)
+        added = true
+    }
+    return added
+}
+
+// UnresolvedImports is used to get the sorted imports of the proto files and their transitive imports,
+// which can not be found in the folders of the proto files and the proto paths
+func (g *Generator) UnresolvedImports(files []string) []string {
+    visited := map[string]bool{}
+    unresolved := map[string]bool{}
+    var walk func(path string, dir string)
+    walk = func(path string, dir string) {
+        if visited[path] {
+            return
+        }
+        visited[path] = true
+        data, err := ioutil.ReadFile(path)
+        if err != nil {
+            return
+        }
+        for _, match := range importPattern.FindAllSubmatch(data, -1) {
+            name := string(match[1])
+            if imported := g.ResolveImport(dir, name); imported != "" {
+                walk(imported, dir)
+            } else {
+                unresolved[name] = true
+            }
+        }
+    }
+    for _, file := range files {
+        walk(file, filepath.Dir(file))
+    }
+    var names []string
+    for name := range unresolved {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// FindModuleRoot is used to find the folder containing go.mod from dir upwards
+func FindModuleRoot(dir string) string {
+    dir, err := filepath.Abs(dir)
+    if err != nil {
+        return ""
+    }
+    for {
+        if info, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !info.IsDir() {
+            return dir
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir {
+            return ""
+        }
+        dir = parent
+    }
+}
+
+// ModuleCacheDir is the folder which caches the downloaded modules of go.mod and go.sum
+var ModuleCacheDir = defaultModuleCacheDir()
+
+func defaultModuleCacheDir() string {
+    if dir, err := os.UserCacheDir(); err == nil {
+        return filepath.Join(dir, "gos", "proto-modules")
+    }
+    return filepath.Join(os.TempDir(), fmt.Sprintf(".gos-%d", os.Getuid()), "proto-modules")
+}
+
+// LoadModules is used to get the downloaded dependencies of the module in root,
+// they are downloaded only if go.mod or go.sum has changed since the last download
+func LoadModules(root string) ([]*module.List, error) {
+    path := filepath.Join(ModuleCacheDir, modulesKey(root)+".json")
+    if data, err := ioutil.ReadFile(path); err == nil {
+        var modules []*module.List
+        if jsoniter.Unmarshal(data, &modules) == nil && downloaded(modules) {
+            return modules, nil
+        }
+    }
+    modules, err := DownloadModules(root)
+    if err != nil {
+        return nil, err
+    }
+    if data, err := jsoniter.Marshal(modules); err == nil && os.MkdirAll(ModuleCacheDir, os.ModePerm) == nil {
+        ioutil.WriteFile(path, data, 0644)
+    }
+    return modules, nil
+}
+
+// modulesKey is used to identify the dependencies of the module in root by go.mod and go.sum
+func modulesKey(root string) string {
+    h := sha256.New()
+    fmt.Fprintln(h, root)
+    for _, name := range []string{"go.mod", "go.sum"} {
+        data, _ := ioutil.ReadFile(filepath.Join(root, name))
+        fmt.Fprintf(h, "%s %d\n", name, len(data))
+        h.Write(data)
+    }
+    return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// downloaded is used to determine whether all the modules are still in the module cache
+func downloaded(modules []*module.List) bool {
+    for _, mod := range modules {
+        if mod.Dir == "" || !isDir(mod.Dir) {
+            return false
+        }
+    }
+    return len(modules) != 0
+}
+
+// DownloadModules is used to download the dependencies of the module in root
+// through the local proxy and get their folders in the module cache
+func DownloadModules(root string) ([]*module.List, error) {
+    stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+    fd := util.GetGoBinaryCMD("mod", []string{"download", "-json"})
+    fd.Env = util.GetEnvWithLocalProxy()
+    fd.Dir = root
+    fd.Stdout = stdout
+    fd.Stderr = stderr
+    err := fd.Run()
+
+    var modules []*module.List
+    decoder := jsoniter.NewDecoder(stdout)
+    for {
+        mod := &module.List{}
+        if e := decoder.Decode(mod); e != nil {
+            if e != io.EOF && err == nil {
+                err = e
+            }
+            break
+        }
+        modules = append(modules, mod)
+    }
+    if err != nil && len(modules) == 0 {
+        if stderr.Len() != 0 {
+            return nil, errors.New(strings.TrimSpace(stderr.String()))
+        }
+        return nil, err
+    }
+    return modules, nil
+}
+
+// ModuleInclude is used to find the module which owns the import and get its include path.
+// The import prefixed by the module path ("github.com/gogo/protobuf/gogoproto/gogo.proto") is mapped to
+// the module folder, the others are searched in the module folders and their third party folders
+// as roots ("validate/validate.proto"), it returns empty string if no module owns the import
+func ModuleInclude(modules []*module.List, name string) string {
+    for _, mod := range modules {
+        if mod.Dir != "" && strings.HasPrefix(name, mod.Path+"/") &&
+            isFile(filepath.Join(mod.Dir, filepath.FromSlash(strings.TrimPrefix(name, mod.Path+"/")))) {
+            return mod.Path + "=" + mod.Dir
+        }
+    }
+    for _, mod := range modules {
+        if mod.Dir == "" {
+            continue
+        }
+        roots := []string{mod.Dir}
+        for _, dir := range thirdPartyDirs {
+            roots = append(roots, filepath.Join(mod.Dir, dir))
+        }
+        for _, root := range roots {
+            if isFile(filepath.Join(root, filepath.FromSlash(name))) {
+                return root
+            }
+        }
+    }
+    return ""
+}
+
+// FindWellKnownTypes is used to find the include folder of the protoc installation,
+// which contains google/protobuf/*.proto
+func FindWellKnownTypes() string {
+    protoc, err := exec.LookPath(ProtocBinaryPath)
+    if err != nil {
+        return ""
+    }
+    if real, err := filepath.EvalSymlinks(protoc); err == nil {
+        protoc = real
+    }
+    for _, dir := range []string{
+        filepath.Join(filepath.Dir(protoc), "..", "include"),
+        filepath.Join(filepath.Dir(protoc), "include"),
+    } {
+        if _, err := os.Stat(filepath.Join(dir, "google", "protobuf", "descriptor.proto")); err == nil {
+            return filepath.Clean(dir)
+        }
+    }
+    return ""
+}
+
+func isDir(path string) bool {
+    info, err := os.Stat(path)
+    return err == nil && info.IsDir()
+}
+
+func isFile(path string) bool {
+    info, err := os.Stat(path)
+    return err == nil && !info.IsDir()
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/json-iterator/go"
+    "github.com/storyicon/gos/pkg/proxy/module"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestModuleInclude(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    gogo := filepath.Join(dir, "protobuf@v1.3.1")
+    validate := filepath.Join(dir, "protoc-gen-validate@v0.4.0")
+    gateway := filepath.Join(dir, "grpc-gateway@v1.14.6")
+    googleapis := filepath.Join(gateway, "third_party", "googleapis")
+    for _, file := range []string{
+        filepath.Join(gogo, "gogoproto", "gogo.proto"),
+        filepath.Join(validate, "validate", "validate.proto"),
+        filepath.Join(googleapis, "google", "api", "annotations.proto"),
+    } {
+        assert.Equal(t, nil, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+        assert.Equal(t, nil, ioutil.WriteFile(file, []byte("syntax = \"proto3\";\n"), 0644))
+    }
+    modules := []*module.List{
+        {Path: "github.com/not/downloaded"},
+        {Path: "github.com/gogo/protobuf", Dir: gogo},
+        {Path: "github.com/envoyproxy/protoc-gen-validate", Dir: validate},
+        {Path: "github.com/grpc-ecosystem/grpc-gateway", Dir: gateway},
+    }
+    assert.Equal(t, "github.com/gogo/protobuf="+gogo, ModuleInclude(modules, "github.com/gogo/protobuf/gogoproto/gogo.proto"))
+    assert.Equal(t, validate, ModuleInclude(modules, "validate/validate.proto"))
+    assert.Equal(t, googleapis, ModuleInclude(modules, "google/api/annotations.proto"))
+    assert.Equal(t, "", ModuleInclude(modules, "github.com/gogo/protobuf/missing.proto"))
+}
+
+func TestGenerator_ResolveModuleIncludes(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    // the annotations of grpc-gateway import google/api/http.proto in the same third party folder,
+    // and the other modules are not added
+    gateway := filepath.Join(dir, "grpc-gateway@v1.14.6")
+    googleapis := filepath.Join(gateway, "third_party", "googleapis")
+    unused := filepath.Join(dir, "unused@v1.0.0")
+    files := map[string]string{
+        filepath.Join(googleapis, "google", "api", "annotations.proto"): "import \"google/api/http.proto\";\n",
+        filepath.Join(googleapis, "google", "api", "http.proto"):        "",
+        filepath.Join(unused, "unused.proto"):                           "",
+        filepath.Join(dir, "api", "local.proto"):                        "",
+        filepath.Join(dir, "api", "user.proto"): "import \"local.proto\";\nimport \"google/api/annotations.proto\";\n" +
+            "import \"missing.proto\";\n",
+    }
+    for file, content := range files {
+        assert.Equal(t, nil, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+        assert.Equal(t, nil, ioutil.WriteFile(file, []byte("syntax = \"proto3\";\n"+content), 0644))
+    }
+    user := filepath.Join(dir, "api", "user.proto")
+
+    g := &Generator{Config: &Config{}}
+    assert.Equal(t, []string{"google/api/annotations.proto", "missing.proto"}, g.UnresolvedImports([]string{user}))
+    assert.Equal(t, true, g.addModuleIncludes([]*module.List{
+        {Path: "example.com/unused", Dir: unused},
+        {Path: "github.com/grpc-ecosystem/grpc-gateway", Dir: gateway},
+    }, g.UnresolvedImports([]string{user})))
+    assert.Equal(t, []string{googleapis}, g.ModulePaths)
+    assert.Equal(t, []string{"missing.proto"}, g.UnresolvedImports([]string{user}))
+}
+
+func TestLoadModules(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    defer func(path string) {
+        ModuleCacheDir = path
+    }(ModuleCacheDir)
+    ModuleCacheDir = filepath.Join(dir, "cache")
+
+    // the cached modules are used without running go mod download
+    root := filepath.Join(dir, "root")
+    assert.Equal(t, nil, os.MkdirAll(root, os.ModePerm))
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(root, "go.mod"), []byte("module x\n"), 0644))
+    modules := []*module.List{{Path: "example.com/a", Dir: dir}}
+    data, err := jsoniter.Marshal(modules)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, nil, os.MkdirAll(ModuleCacheDir, os.ModePerm))
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(ModuleCacheDir, modulesKey(root)+".json"), data, 0644))
+
+    loaded, err := LoadModules(root)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 1, len(loaded))
+    assert.Equal(t, dir, loaded[0].Dir)
+
+    // go.sum changes the key
+    key := modulesKey(root)
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(root, "go.sum"), []byte("example.com/a v1.0.0 h1:x\n"), 0644))
+    assert.NotEqual(t, key, modulesKey(root))
+}
+
+func TestFindModuleRoot(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+    dir, _ = filepath.EvalSymlinks(dir)
+
+    sub := filepath.Join(dir, "api", "v1")
+    assert.Equal(t, nil, os.MkdirAll(sub, os.ModePerm))
+    assert.Equal(t, nil, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644))
+    assert.Equal(t, dir, FindModuleRoot(sub))
+}
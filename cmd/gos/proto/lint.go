@@ -0,0 +1,214 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "regexp"
+    "sort"
+
+    "github.com/storyicon/gos/pkg/protoparse"
+
+    "github.com/spf13/cobra"
+)
+
+// CmdLint is the command line for linting proto files
+var CmdLint = &cobra.Command{
+    Use:   "lint [file]",
+    Short: "check the style of proto files",
+    Long: `
+Usage:
+    gos proto lint [--exclude pattern] [file]
+
+    [file] the proto files to check, the same as gos proto, the default is all/all
+
+    The proto files are parsed by gos itself, protoc is not required. The rules are:
+    PACKAGE_DEFINED             the package is declared
+    PACKAGE_LOWER_SNAKE_CASE    the package is lower_snake_case separated by dots, such as foo.bar.v1
+    GO_PACKAGE_DEFINED          option go_package is declared
+    MESSAGE_PASCAL_CASE         the names of messages are PascalCase
+    FIELD_LOWER_SNAKE_CASE      the names of fields and oneofs are lower_snake_case
+    ENUM_PASCAL_CASE            the names of enums are PascalCase
+    ENUM_VALUE_UPPER_SNAKE_CASE the names of enum values are UPPER_SNAKE_CASE
+    SERVICE_PASCAL_CASE         the names of services are PascalCase
+    RPC_PASCAL_CASE             the names of rpcs are PascalCase
+
+    The issues are printed as file:line:column: message (RULE), gos exits with code 1 if there is any issue
+
+    - Check all proto files in the current directory and all subdirectories
+    gos proto lint
+
+    - Check the proto files under ./api
+    gos proto lint ./api/...
+`,
+    Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+    CmdLint.Run = RunLint
+}
+
+var (
+    packagePattern        = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*$`)
+    pascalCasePattern     = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+    lowerSnakeCasePattern = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+    upperSnakeCasePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+)
+
+// Issue is a problem found in the proto file
+type Issue struct {
+    File    string
+    Line    int
+    Column  int
+    Rule    string
+    Message string
+}
+
+func (i *Issue) String() string {
+    return fmt.Sprintf("%s:%d:%d: %s (%s)", i.File, i.Line, i.Column, i.Message, i.Rule)
+}
+
+func newIssue(file *protoparse.File, pos protoparse.Position, rule string, format string, args ...interface{}) *Issue {
+    return &Issue{
+        File:    file.Name,
+        Line:    pos.Line,
+        Column:  pos.Column,
+        Rule:    rule,
+        Message: fmt.Sprintf(format, args...),
+    }
+}
+
+// SortIssues is used to sort the issues by location
+func SortIssues(issues []*Issue) {
+    sort.SliceStable(issues, func(i, j int) bool {
+        a, b := issues[i], issues[j]
+        if a.File != b.File {
+            return a.File < b.File
+        }
+        if a.Line != b.Line {
+            return a.Line < b.Line
+        }
+        return a.Column < b.Column
+    })
+}
+
+// Lint is used to check the style of the parsed proto file
+func Lint(file *protoparse.File) []*Issue {
+    var issues []*Issue
+    if file.Package == "" {
+        issues = append(issues, newIssue(file, file.Position, "PACKAGE_DEFINED", "package is not declared"))
+    } else if !packagePattern.MatchString(file.Package) {
+        issues = append(issues, newIssue(file, file.Position, "PACKAGE_LOWER_SNAKE_CASE", "package %q should be lower_snake_case separated by dots", file.Package))
+    }
+    if file.Options["go_package"] == "" {
+        issues = append(issues, newIssue(file, file.Position, "GO_PACKAGE_DEFINED", "option go_package is not declared"))
+    }
+    for _, message := range file.Messages {
+        issues = append(issues, lintMessage(file, message)...)
+    }
+    for _, enum := range file.Enums {
+        issues = append(issues, lintEnum(file, enum)...)
+    }
+    for _, service := range file.Services {
+        if !pascalCasePattern.MatchString(service.Name) {
+            issues = append(issues, newIssue(file, service.Position, "SERVICE_PASCAL_CASE", "service %q should be PascalCase", service.Name))
+        }
+        for _, method := range service.Methods {
+            if !pascalCasePattern.MatchString(method.Name) {
+                issues = append(issues, newIssue(file, method.Position, "RPC_PASCAL_CASE", "rpc %q should be PascalCase", method.Name))
+            }
+        }
+    }
+    SortIssues(issues)
+    return issues
+}
+
+func lintMessage(file *protoparse.File, message *protoparse.Message) []*Issue {
+    var issues []*Issue
+    if !pascalCasePattern.MatchString(message.Name) {
+        issues = append(issues, newIssue(file, message.Position, "MESSAGE_PASCAL_CASE", "message %q should be PascalCase", message.Name))
+    }
+    oneofs := map[string]bool{}
+    for _, field := range message.Fields {
+        if !lowerSnakeCasePattern.MatchString(field.Name) {
+            issues = append(issues, newIssue(file, field.Position, "FIELD_LOWER_SNAKE_CASE", "field %q should be lower_snake_case", field.Name))
+        }
+        if field.Oneof != "" && !oneofs[field.Oneof] {
+            oneofs[field.Oneof] = true
+            if !lowerSnakeCasePattern.MatchString(field.Oneof) {
+                issues = append(issues, newIssue(file, field.Position, "FIELD_LOWER_SNAKE_CASE", "oneof %q should be lower_snake_case", field.Oneof))
+            }
+        }
+    }
+    for _, nested := range message.Messages {
+        issues = append(issues, lintMessage(file, nested)...)
+    }
+    for _, enum := range message.Enums {
+        issues = append(issues, lintEnum(file, enum)...)
+    }
+    return issues
+}
+
+func lintEnum(file *protoparse.File, enum *protoparse.Enum) []*Issue {
+    var issues []*Issue
+    if !pascalCasePattern.MatchString(enum.Name) {
+        issues = append(issues, newIssue(file, enum.Position, "ENUM_PASCAL_CASE", "enum %q should be PascalCase", enum.Name))
+    }
+    for _, value := range enum.Values {
+        if !upperSnakeCasePattern.MatchString(value.Name) {
+            issues = append(issues, newIssue(file, value.Position, "ENUM_VALUE_UPPER_SNAKE_CASE", "enum value %q should be UPPER_SNAKE_CASE", value.Name))
+        }
+    }
+    return issues
+}
+
+// RunLint is used to execute the lint command
+func RunLint(cmd *cobra.Command, args []string) {
+    filter, err := getFilter(cmd)
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    files, err := GetFinder(getTarget(args), filter)()
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+
+    var issues []*Issue
+    var failed bool
+    for _, name := range relativePaths(files) {
+        file, err := protoparse.ParseFile(name)
+        if err != nil {
+            log.Println(err)
+            failed = true
+            continue
+        }
+        issues = append(issues, Lint(file)...)
+    }
+    for _, issue := range issues {
+        fmt.Println(issue)
+    }
+    if failed || len(issues) != 0 {
+        log.Printf("%d issues are found in %d files", len(issues), len(files))
+        os.Exit(1)
+        return
+    }
+    log.Printf("%d files are checked, no issue is found :)", len(files))
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "testing"
+
+    "github.com/storyicon/gos/pkg/protoparse"
+    "github.com/stretchr/testify/assert"
+)
+
+func getIssues(issues []*Issue) []string {
+    var r []string
+    for _, issue := range issues {
+        r = append(r, issue.String())
+    }
+    return r
+}
+
+func TestLint(t *testing.T) {
+    file, err := protoparse.Parse("user.proto", `syntax = "proto3";
+package Example.User;
+message user_info {
+    string userName = 1;
+    oneof Contact { string email = 2; }
+    enum status { active = 0; }
+}
+service userService {
+    rpc get_user(user_info) returns (user_info);
+}
+`)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{
+        `user.proto:1:1: package "Example.User" should be lower_snake_case separated by dots (PACKAGE_LOWER_SNAKE_CASE)`,
+        `user.proto:1:1: option go_package is not declared (GO_PACKAGE_DEFINED)`,
+        `user.proto:3:1: message "user_info" should be PascalCase (MESSAGE_PASCAL_CASE)`,
+        `user.proto:4:5: field "userName" should be lower_snake_case (FIELD_LOWER_SNAKE_CASE)`,
+        `user.proto:5:21: oneof "Contact" should be lower_snake_case (FIELD_LOWER_SNAKE_CASE)`,
+        `user.proto:6:5: enum "status" should be PascalCase (ENUM_PASCAL_CASE)`,
+        `user.proto:6:19: enum value "active" should be UPPER_SNAKE_CASE (ENUM_VALUE_UPPER_SNAKE_CASE)`,
+        `user.proto:8:1: service "userService" should be PascalCase (SERVICE_PASCAL_CASE)`,
+        `user.proto:9:5: rpc "get_user" should be PascalCase (RPC_PASCAL_CASE)`,
+    }, getIssues(Lint(file)))
+
+    file, err = protoparse.Parse("user.proto", `syntax = "proto3";
+package example.user.v1;
+option go_package = "example.com/user/v1";
+message UserInfo { string user_name = 1; }
+`)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 0, len(Lint(file)))
+}
@@ -23,7 +23,10 @@ import (
     "os/exec"
     "path/filepath"
     "runtime"
+    "strconv"
+    "strings"
     "sync"
+    "time"
 
     "github.com/storyicon/gos/pkg/concurrent"
 
@@ -38,11 +41,18 @@ var CmdProto = &cobra.Command{
     Short: "quick and easy compilation of proto files",
     Long: `
 Usage:
-    gos proto [file]
+    gos proto [flags] [file]
+    gos proto lint [file]
+    gos proto breaking --against revision [file]
+    gos proto graph [--format text|dot|json] [file]
 
-    [file] the proto file you wanna to compile, gos supports two additional wildcards: 
+    [file] the proto file you wanna to compile, gos supports three additional wildcards: 
     "all" means compiling all proto files under the current folder (excluding subfolders);
-    "all/all" means compiling all proto files in the current directory and all subdirectories
+    "all/all" means compiling all proto files in the current directory and all subdirectories;
+    "dir/..." means compiling all proto files in dir and its subdirectories, such as ./api/...
+
+    The plugins, protoc and import paths can also be configured in gos-proto.json of the current folder,
+    see the README of gos for the config file and how the imports are resolved
     
     - Compile a single file
     gos proto helloworld.proto
@@ -52,31 +62,139 @@ Usage:
 
     - Compile all proto files in the current directory and all subdirectories
     gos proto all/all
+
+    - Compile all proto files under ./api and its subdirectories, except the vendored googleapis
+    gos proto --exclude third_party/googleapis/ ./api/...
+
+    - Verify that the generated files are up to date in CI
+    gos proto --check all/all
+
+    - Regenerate the proto files when they change
+    gos proto --watch all/all
+
+    - Compile with protoc-gen-go and protoc-gen-go-grpc
+    gos proto --plugin go:paths=source_relative --plugin go-grpc:paths=source_relative all/all
 `,
     Args: cobra.ExactArgs(1),
 }
 
 func init() {
     CmdProto.Run = Run
+    CmdProto.AddCommand(CmdLint, CmdBreaking, CmdGraph)
+    CmdProto.PersistentFlags().String("config", "", "the config file, the default is "+DefaultConfigFile+" in the current folder if it exists")
+    CmdProto.Flags().StringArray("plugin", nil, "the protoc plugin and its options such as go:paths=source_relative, can be specified multiple times")
+    CmdProto.Flags().StringArrayP("include", "I", nil, "the additional proto import path, can be specified multiple times")
+    CmdProto.PersistentFlags().StringArray("exclude", nil, "skip the files and folders matching the pattern in .gitignore syntax, can be specified multiple times")
+    CmdProto.PersistentFlags().Bool("no-ignore", false, "do not skip vendor/, node_modules/, .git/ and the patterns in .gosignore and .gitignore")
+    CmdProto.Flags().String("descriptor_set_out", "", "write a FileDescriptorSet of the proto files and their imports to the file")
+    CmdProto.Flags().Bool("check", false, "verify that the generated files are up to date without modifying them")
+    CmdProto.Flags().Bool("watch", false, "watch the proto files and regenerate them when they change")
+    CmdProto.Flags().Duration("interval", time.Second, "the polling interval of --watch")
+    CmdProto.Flags().Bool("force", false, "regenerate all proto files even if they are up to date")
+    CmdProto.Flags().Bool("no-modules", false, "do not add the module dependencies in go.mod to the proto import paths")
+    CmdProto.Flags().Bool("skip-checksum", false, "install the pinned protoc without a configured checksum and print its sha256")
 }
 
-// GoPathSrc is the address of $GOPATH/src
-var GoPathSrc = filepath.Join(os.Getenv("GOPATH"), "src")
+// ProtocBinaryPath is the location of protoc binary
+var ProtocBinaryPath = "protoc"
+
+// Generator is used to compile proto files with the configured plugins
+type Generator struct {
+    *Config
+    // ProtoPaths is the resolved proto import paths
+    ProtoPaths []string
+    // ModulePaths is the import paths of the module dependencies owning the imports,
+    // which are added on demand by ResolveModuleIncludes
+    ModulePaths []string
+    // State is used to skip the groups which are up to date,
+    // all groups will be generated when it is nil
+    State *State
+    // Force is used to generate all groups and refresh their state
+    Force bool
+    // Verbose is used to print the result of each group
+    Verbose bool
+    // Filter is used to exclude the files and folders when searching proto files
+    Filter *Filter
+    // OutputRoot is used to redirect the generated files into another folder,
+    // in which the layout is the same as the current folder
+    OutputRoot string
+}
+
+// NewGenerator is used to initialize a Generator
+func NewGenerator(config *Config) (*Generator, error) {
+    paths, err := config.ResolveIncludes()
+    if err != nil {
+        return nil, err
+    }
+    return &Generator{
+        Config:     config,
+        ProtoPaths: paths,
+    }, nil
+}
 
 // Run command
 func Run(cmd *cobra.Command, args []string) {
     proto := args[0]
 
-    var err error
-    switch proto {
-    case "all":
-        err = WalkCurrent()
-    case "all/all":
-        err = WalkIter()
-    default:
-        err = Generate(proto)
+    config, err := getConfig(cmd)
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+
+    for _, plugin := range config.DeprecatedPlugins() {
+        log.Printf("%s: the option %s is deprecated and fails with protoc-gen-go of google.golang.org/protobuf, "+
+            "use --plugin go:paths=source_relative --plugin go-grpc:paths=source_relative instead", plugin.Name, strings.Join(plugin.Options, ","))
+    }
+    if err := config.InstallToolchain(); err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+
+    g, err := NewGenerator(config)
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    g.Force, _ = cmd.Flags().GetBool("force")
+    if g.Filter, err = newFilter(cmd, config); err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    if g.State, err = LoadState(DefaultStateFile); err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    find := GetFinder(proto, g.Filter)
+    if check, _ := cmd.Flags().GetBool("check"); check {
+        os.Exit(runCheck(g, find))
+        return
+    }
+    if watch, _ := cmd.Flags().GetBool("watch"); watch {
+        interval, _ := cmd.Flags().GetDuration("interval")
+        g.Verbose = true
+        g.Watch(find, interval)
+        return
+    }
+
+    files, err := find()
+    if err == nil {
+        err = g.GenerateFiles(files)
+    }
+    if out, _ := cmd.Flags().GetString("descriptor_set_out"); err == nil && out != "" {
+        err = g.WriteDescriptorSet(files, out)
     }
 
+    if g.State != nil {
+        if e := g.State.Save(); e != nil {
+            log.Println(e)
+        }
+    }
     if err != nil {
         log.Println(err)
         os.Exit(1)
@@ -85,104 +203,352 @@ func Run(cmd *cobra.Command, args []string) {
     log.Println("Good job, you are ready to go :)")
 }
 
-// WalkIter is used to traverse the current folder and its subfolders,
-// find all proto files and execute the generate command
-func WalkIter() error {
-    dir, err := os.Getwd()
+// getConfig is used to load the config file and apply the command line flags
+func getConfig(cmd *cobra.Command) (*Config, error) {
+    path, _ := cmd.Flags().GetString("config")
+    config, err := LoadConfig(path)
     if err != nil {
-        return err
+        return nil, err
     }
 
-    var errs error
-    var lock sync.Mutex
-    var c = concurrent.New(runtime.NumCPU())
+    plugins, _ := cmd.Flags().GetStringArray("plugin")
+    if len(plugins) != 0 {
+        config.Plugins = nil
+        for _, value := range plugins {
+            plugin, err := ParsePlugin(value)
+            if err != nil {
+                return nil, err
+            }
+            config.Plugins = append(config.Plugins, plugin)
+        }
+    }
 
-    fatalErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+    includes, _ := cmd.Flags().GetStringArray("include")
+    config.Includes = append(config.Includes, includes...)
+    excludes, _ := cmd.Flags().GetStringArray("exclude")
+    config.Excludes = append(config.Excludes, excludes...)
+    if disable, _ := cmd.Flags().GetBool("no-modules"); disable {
+        config.DisableModules = true
+    }
+    if skip, _ := cmd.Flags().GetBool("skip-checksum"); skip && config.Protoc != nil {
+        config.Protoc.SkipChecksum = true
+    }
+    return config, nil
+}
+
+// getFilter is used to load the config file and get the filter of proto files
+func getFilter(cmd *cobra.Command) (*Filter, error) {
+    config, err := getConfig(cmd)
+    if err != nil {
+        return nil, err
+    }
+    return newFilter(cmd, config)
+}
+
+func newFilter(cmd *cobra.Command, config *Config) (*Filter, error) {
+    noIgnore, _ := cmd.Flags().GetBool("no-ignore")
+    return NewFilter(".", config.Excludes, noIgnore)
+}
+
+// getTarget is used to get the proto files specified by the arguments of sub commands,
+// the default is all/all
+func getTarget(args []string) string {
+    if len(args) == 0 {
+        return "all/all"
+    }
+    return args[0]
+}
+
+// GetFinder is used to get the function which finds the proto files specified by the argument,
+// "all", "all/all" and "dir/..." are wildcards, others are proto files
+func GetFinder(proto string, filter *Filter) func() ([]string, error) {
+    switch {
+    case proto == "all":
+        return func() ([]string, error) {
+            return FindCurrent(filter)
+        }
+    case proto == "all/all" || proto == "...":
+        return func() ([]string, error) {
+            return FindIter(".", filter)
+        }
+    case strings.HasSuffix(proto, "/..."):
+        return func() ([]string, error) {
+            return FindIter(strings.TrimSuffix(proto, "/..."), filter)
+        }
+    default:
+        return func() ([]string, error) {
+            return []string{proto}, nil
+        }
+    }
+}
+
+// FindIter is used to find all proto files in root and its subfolders,
+// the files and folders excluded by filter are skipped
+func FindIter(root string, filter *Filter) ([]string, error) {
+    dir, err := filepath.Abs(root)
+    if err != nil {
+        return nil, err
+    }
+
+    var files []string
+    err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
         if err != nil {
             return nil
         }
-        c.Add(1)
-        go func() {
-            defer c.Done()
-            ext := filepath.Ext(path)
-            if ext != ".proto" {
-                return
+        if info.IsDir() {
+            if path != dir && filter.Excluded(path, true) {
+                return filepath.SkipDir
             }
-            err = Generate(path)
-            if err != nil {
-                lock.Lock()
-                errs = multierror.Append(errs, err)
-                lock.Unlock()
-            }
-        }()
+            return nil
+        }
+        if filepath.Ext(path) == ".proto" && !filter.Excluded(path, false) {
+            files = append(files, path)
+        }
         return nil
     })
-    c.Wait()
-
-    if fatalErr != nil {
-        errs = multierror.Append(errs, fatalErr)
-    }
-
-    return errs
+    return files, err
 }
 
-// WalkCurrent is used to traverse all proto files
-// in the current folder and execute the generate command
-func WalkCurrent() error {
+// FindCurrent is used to find all proto files in the current folder,
+// the files excluded by filter are skipped
+func FindCurrent(filter *Filter) ([]string, error) {
     dir, err := os.Getwd()
     if err != nil {
-        return err
+        return nil, err
     }
     elements, err := ioutil.ReadDir(dir)
     if err != nil {
-        return nil
+        return nil, nil
     }
 
-    var lock sync.Mutex
-    var errs error
-    var c = concurrent.New(runtime.NumCPU())
+    var files []string
     for _, ele := range elements {
         if ele.IsDir() {
             continue
         }
         name := ele.Name()
-        ext := filepath.Ext(name)
-
-        if ext != ".proto" {
-            continue
+        if filepath.Ext(name) == ".proto" && !filter.Excluded(name, false) {
+            files = append(files, name)
         }
+    }
+    return files, nil
+}
+
+// GenerateFiles is used to group the proto files by folder and go_package,
+// and execute the generate command once per group
+func (g *Generator) GenerateFiles(files []string) error {
+    groups, err := GroupFiles(files)
+    if err != nil {
+        return err
+    }
+    if err := g.ResolveModuleIncludes(files); err != nil {
+        log.Printf("failed to resolve the proto paths of modules: %s", err)
+    }
+
+    var tool string
+    if g.State != nil {
+        tool = g.GetToolFingerprint()
+    }
 
+    var lock sync.Mutex
+    var errs error
+    var skipped int
+    var c = concurrent.New(runtime.NumCPU())
+    for _, group := range groups {
         c.Add(1)
-        go func(name string) {
+        go func(group *Group) {
             defer c.Done()
-            err := Generate(name)
+            generated, err := g.generateIncrementally(group, tool)
+            lock.Lock()
+            defer lock.Unlock()
             if err != nil {
-                lock.Lock()
-                defer lock.Unlock()
+                if g.Verbose {
+                    log.Printf("* %s: failed", GetRelativeNames(group))
+                }
                 errs = multierror.Append(errs, err)
+                return
+            }
+            if !generated {
+                skipped++
+                return
             }
-        }(name)
+            if g.Verbose {
+                log.Printf("* %s: generated", GetRelativeNames(group))
+            }
+        }(group)
     }
     c.Wait()
+    if skipped != 0 && !g.Verbose {
+        log.Printf("%d of %d groups are up to date, use --force to regenerate them", skipped, len(groups))
+    }
     return errs
 }
 
+// generateIncrementally is used to generate the group when its fingerprint changes
+func (g *Generator) generateIncrementally(group *Group, tool string) (bool, error) {
+    if g.State == nil {
+        return true, g.GenerateGroup(group)
+    }
+    key := GetStateKey(group)
+    fingerprint, err := g.GetFingerprint(group, tool)
+    if err != nil {
+        return false, err
+    }
+    if !g.Force && g.State.IsUpToDate(key, fingerprint) {
+        return false, nil
+    }
+    outputs, err := g.generateOutputs(group)
+    if err != nil {
+        return false, err
+    }
+    g.State.Set(key, fingerprint, outputs)
+    return true, nil
+}
+
+// generateOutputs is used to generate the group into a temporary folder and move the generated files
+// into the output folders of plugins, it returns the sha256 of each generated file
+func (g *Generator) generateOutputs(group *Group) (map[string]string, error) {
+    tmp, err := ioutil.TempDir("", "gos-proto-generate")
+    if err != nil {
+        return nil, err
+    }
+    defer os.RemoveAll(tmp)
+
+    config := *g.Config
+    config.Plugins = nil
+    staged := *g
+    staged.Config = &config
+    staged.OutputRoot = ""
+    for i, plugin := range g.Plugins {
+        redirected := *plugin
+        redirected.Out = filepath.Join(tmp, strconv.Itoa(i))
+        staged.Plugins = append(staged.Plugins, &redirected)
+    }
+    if err := staged.GenerateGroup(group); err != nil {
+        return nil, err
+    }
+
+    outputs := map[string]string{}
+    for i, plugin := range g.Plugins {
+        out, err := g.getOutputDir(group, plugin)
+        if err != nil {
+            return nil, err
+        }
+        if !filepath.IsAbs(out) {
+            out = filepath.Join(group.Dir, out)
+        }
+        if err := moveOutputs(filepath.Join(tmp, strconv.Itoa(i)), out, outputs); err != nil {
+            return nil, err
+        }
+    }
+    return outputs, nil
+}
+
+// moveOutputs is used to copy the generated files in src into dst and record their sha256,
+// the files whose content is not changed are not rewritten
+func moveOutputs(src string, dst string, outputs map[string]string) error {
+    return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+        if err != nil || info.IsDir() {
+            return err
+        }
+        rel, err := filepath.Rel(src, path)
+        if err != nil {
+            return err
+        }
+        data, err := ioutil.ReadFile(path)
+        if err != nil {
+            return err
+        }
+        target := filepath.Join(dst, rel)
+        outputs[filepath.ToSlash(target)] = hashBytes(data)
+        if current, err := ioutil.ReadFile(target); err == nil && bytes.Equal(current, data) {
+            return nil
+        }
+        if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+            return err
+        }
+        return ioutil.WriteFile(target, data, 0644)
+    })
+}
+
 // Generate is used to execute the generate command for the specified proto file
-func Generate(proto string) error {
-    path, name := filepath.Split(proto)
-    fd := exec.Command("protoc", []string{
-        "--proto_path=" + GoPathSrc,
-        "--go_out=plugins=grpc:.",
-        "--proto_path=.",
-        name,
-    }...)
+func (g *Generator) Generate(proto string) error {
+    return g.GenerateFiles([]string{proto})
+}
+
+// GenerateGroup is used to execute the generate command for a group of proto files
+func (g *Generator) GenerateGroup(group *Group) error {
+    args := g.protoPathArgs()
+    for _, plugin := range g.Plugins {
+        out, err := g.getOutputDir(group, plugin)
+        if err != nil {
+            return err
+        }
+        if out != "" {
+            dir := out
+            if !filepath.IsAbs(dir) {
+                dir = filepath.Join(group.Dir, out)
+            }
+            if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+                return err
+            }
+        }
+        redirected := *plugin
+        redirected.Out = out
+        args = append(args, redirected.Args()...)
+    }
+    fd := exec.Command(ProtocBinaryPath, append(args, group.Files...)...)
     stderr := &bytes.Buffer{}
     fd.Stdout = stderr
     fd.Stderr = stderr
-    fd.Dir = path
+    fd.Dir = group.Dir
     if err := fd.Run(); err != nil {
-        err = fmt.Errorf("%s: %s", proto, stderr.String())
+        err = fmt.Errorf("%s: %s", group, stderr.String())
         return err
     }
     return nil
 }
+
+// protoPathArgs is used to get the --proto_path arguments of protoc, which is executed in the folder of the group
+func (g *Generator) protoPathArgs() []string {
+    args := []string{"--proto_path=."}
+    for _, include := range g.includes() {
+        args = append(args, "--proto_path="+include)
+    }
+    return args
+}
+
+// includes is used to get the proto paths followed by the module paths
+func (g *Generator) includes() []string {
+    includes := make([]string, 0, len(g.ProtoPaths)+len(g.ModulePaths))
+    return append(append(includes, g.ProtoPaths...), g.ModulePaths...)
+}
+
+// getOutputDir is used to get the output folder of plugin, which is relative to the group,
+// it is redirected into OutputRoot when OutputRoot is specified, in which case
+// the output folder must be inside the current folder
+func (g *Generator) getOutputDir(group *Group, plugin *Plugin) (string, error) {
+    if g.OutputRoot == "" {
+        return plugin.Out, nil
+    }
+    dir, err := filepath.Abs(group.Dir)
+    if err != nil {
+        return "", err
+    }
+    wd, err := os.Getwd()
+    if err != nil {
+        return "", err
+    }
+    rel, err := filepath.Rel(wd, dir)
+    if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", fmt.Errorf("%s: %s", group, ErrOutsideWorkDir)
+    }
+    if filepath.IsAbs(plugin.Out) {
+        return "", fmt.Errorf("%s: %s", plugin.Name, ErrOutputOutsideWorkDir)
+    }
+    out := filepath.Join(g.OutputRoot, rel, plugin.Out)
+    if rel, err := filepath.Rel(g.OutputRoot, out); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+        return "", fmt.Errorf("%s: %s", plugin.Name, ErrOutputOutsideWorkDir)
+    }
+    return out, nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/json-iterator/go"
+)
+
+// DefaultStateFile is the file recording the fingerprints of generated groups
+const DefaultStateFile = ".gos-proto-state.json"
+
+var importPattern = regexp.MustCompile(`(?m)^\s*import\s+(?:public\s+|weak\s+)?"([^"]+)"\s*;`)
+
+// State records the fingerprint and the generated files of each group generated last time
+type State struct {
+    // Groups maps the files of group to how it was generated
+    Groups map[string]*GroupState
+
+    path string
+    lock sync.Mutex
+}
+
+// GroupState records the fingerprint of group and the files generated from it
+type GroupState struct {
+    Fingerprint string
+    // Outputs maps the generated files to their sha256
+    Outputs map[string]string
+}
+
+// stateFile is the layout of the state file, the groups and outputs are written
+// as lists sorted by their keys, so that the state file is stable
+type stateFile struct {
+    Groups []*stateGroup `json:"groups"`
+}
+
+type stateGroup struct {
+    Files       string         `json:"files"`
+    Fingerprint string         `json:"fingerprint"`
+    Outputs     []*stateOutput `json:"outputs"`
+}
+
+type stateOutput struct {
+    Path   string `json:"path"`
+    SHA256 string `json:"sha256"`
+}
+
+// LoadState is used to load the state file, an empty state is returned if it does not exist,
+// or it is written by an older version of gos
+func LoadState(path string) (*State, error) {
+    state := &State{
+        Groups: map[string]*GroupState{},
+        path:   path,
+    }
+    data, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return state, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    file := &stateFile{}
+    if err := jsoniter.Unmarshal(data, file); err != nil {
+        return state, nil
+    }
+    for _, group := range file.Groups {
+        outputs := map[string]string{}
+        for _, output := range group.Outputs {
+            outputs[output.Path] = output.SHA256
+        }
+        state.Groups[group.Files] = &GroupState{
+            Fingerprint: group.Fingerprint,
+            Outputs:     outputs,
+        }
+    }
+    return state, nil
+}
+
+// IsUpToDate is used to determine whether the group has been generated with the fingerprint,
+// and the files generated from it are neither deleted nor modified
+func (s *State) IsUpToDate(key string, fingerprint string) bool {
+    s.lock.Lock()
+    group := s.Groups[key]
+    s.lock.Unlock()
+    if group == nil || group.Fingerprint != fingerprint {
+        return false
+    }
+    for path, hash := range group.Outputs {
+        data, err := ioutil.ReadFile(filepath.FromSlash(path))
+        if err != nil || hashBytes(data) != hash {
+            return false
+        }
+    }
+    return true
+}
+
+// Set is used to record the fingerprint of group and the hashes of the files generated from it
+func (s *State) Set(key string, fingerprint string, outputs map[string]string) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    s.Groups[key] = &GroupState{
+        Fingerprint: fingerprint,
+        Outputs:     outputs,
+    }
+}
+
+func hashBytes(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// Save is used to write the state file
+func (s *State) Save() error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+    var keys []string
+    for key := range s.Groups {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    file := &stateFile{}
+    for _, key := range keys {
+        group := &stateGroup{
+            Files:       key,
+            Fingerprint: s.Groups[key].Fingerprint,
+        }
+        outputs := s.Groups[key].Outputs
+        var paths []string
+        for path := range outputs {
+            paths = append(paths, path)
+        }
+        sort.Strings(paths)
+        for _, path := range paths {
+            group.Outputs = append(group.Outputs, &stateOutput{Path: path, SHA256: outputs[path]})
+        }
+        file.Groups = append(file.Groups, group)
+    }
+    data, err := jsoniter.MarshalIndent(file, "", "  ")
+    if err != nil {
+        return err
+    }
+    return ioutil.WriteFile(s.path, data, 0644)
+}
+
+// GetStateKey is used to get the key of group in the state file
+func GetStateKey(group *Group) string {
+    var paths []string
+    for _, file := range group.Files {
+        path, err := filepath.Abs(filepath.Join(group.Dir, file))
+        if err != nil {
+            path = filepath.Join(group.Dir, file)
+        }
+        paths = append(paths, filepath.ToSlash(path))
+    }
+    sort.Strings(paths)
+    return strings.Join(paths, ",")
+}
+
+// GetToolFingerprint is used to get the fingerprint of protoc, plugins, options and import paths
+func (g *Generator) GetToolFingerprint() string {
+    h := sha256.New()
+    fmt.Fprintln(h, "protoc", binaryIdentity(ProtocBinaryPath))
+    for _, plugin := range g.Plugins {
+        path := plugin.Path
+        if path == "" {
+            path = "protoc-gen-" + plugin.Name
+        }
+        fmt.Fprintln(h, "plugin", plugin.Module, binaryIdentity(path), plugin.Args())
+    }
+    fmt.Fprintln(h, "paths", g.ProtoPaths)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// binaryIdentity identifies the binary by its location, size and modification time
+func binaryIdentity(name string) string {
+    path, err := exec.LookPath(name)
+    if err != nil {
+        return name
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return path
+    }
+    return fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano())
+}
+
+// GetFingerprint is used to get the fingerprint of the group, which covers the content
+// of its proto files, their transitive imports and the tool fingerprint
+func (g *Generator) GetFingerprint(group *Group, tool string) (string, error) {
+    h := sha256.New()
+    fmt.Fprintln(h, tool)
+    visited := map[string]bool{}
+    for _, file := range group.Files {
+        if err := g.hashFile(h, filepath.Join(group.Dir, file), group.Dir, visited); err != nil {
+            return "", err
+        }
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (g *Generator) hashFile(w io.Writer, path string, dir string, visited map[string]bool) error {
+    if visited[path] {
+        return nil
+    }
+    visited[path] = true
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    fmt.Fprintf(w, "%s %d\n", filepath.ToSlash(path), len(data))
+    w.Write(data)
+
+    for _, match := range importPattern.FindAllSubmatch(data, -1) {
+        name := string(match[1])
+        imported := g.ResolveImport(dir, name)
+        if imported == "" {
+            // the import can not be found, protoc will report it
+            fmt.Fprintln(w, "unresolved", name)
+            continue
+        }
+        if err := g.hashFile(w, imported, dir, visited); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ResolveImport is used to find the imported proto file in dir and the proto paths,
+// it returns empty string when the file can not be found
+func (g *Generator) ResolveImport(dir string, name string) string {
+    candidates := []string{filepath.Join(dir, filepath.FromSlash(name))}
+    for _, include := range g.includes() {
+        if i := strings.Index(include, "="); i != -1 {
+            virtual, disk := include[:i], include[i+1:]
+            if strings.HasPrefix(name, virtual+"/") {
+                candidates = append(candidates, filepath.Join(disk, filepath.FromSlash(name[len(virtual)+1:])))
+            }
+            continue
+        }
+        candidates = append(candidates, filepath.Join(include, filepath.FromSlash(name)))
+    }
+    for _, candidate := range candidates {
+        if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+            return candidate
+        }
+    }
+    return ""
+}
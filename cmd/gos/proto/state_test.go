@@ -0,0 +1,132 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "runtime"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestGenerator_GetFingerprint(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    shared := filepath.Join(dir, "shared")
+    assert.Equal(t, nil, os.MkdirAll(filepath.Join(shared, "common"), os.ModePerm))
+    common := filepath.Join(shared, "common", "types.proto")
+    assert.Equal(t, nil, ioutil.WriteFile(common, []byte("syntax = \"proto3\";\n"), 0644))
+    user := filepath.Join(dir, "user.proto")
+    assert.Equal(t, nil, ioutil.WriteFile(user, []byte("syntax = \"proto3\";\nimport \"example.com/shared/common/types.proto\";\n"), 0644))
+
+    g := &Generator{
+        Config:     DefaultConfig(),
+        ProtoPaths: []string{"example.com/shared=" + shared},
+    }
+    assert.Equal(t, common, g.ResolveImport(dir, "example.com/shared/common/types.proto"))
+
+    group := &Group{Dir: dir, Files: []string{"user.proto"}}
+    before, err := g.GetFingerprint(group, "tool")
+    assert.Equal(t, nil, err)
+    again, err := g.GetFingerprint(group, "tool")
+    assert.Equal(t, nil, err)
+    assert.Equal(t, before, again)
+
+    other, err := g.GetFingerprint(group, "another tool")
+    assert.Equal(t, nil, err)
+    assert.NotEqual(t, before, other)
+
+    assert.Equal(t, nil, ioutil.WriteFile(common, []byte("syntax = \"proto3\";\nmessage Empty {}\n"), 0644))
+    after, err := g.GetFingerprint(group, "tool")
+    assert.Equal(t, nil, err)
+    assert.NotEqual(t, before, after)
+}
+
+func TestState_Save(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    output := filepath.Join(dir, "a.pb.go")
+    assert.Equal(t, nil, ioutil.WriteFile(output, []byte("generated"), 0644))
+    path := filepath.Join(dir, DefaultStateFile)
+    state, err := LoadState(path)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, false, state.IsUpToDate("a.proto", "x"))
+    state.Set("a.proto", "x", map[string]string{filepath.ToSlash(output): hashBytes([]byte("generated"))})
+    assert.Equal(t, nil, state.Save())
+
+    state, err = LoadState(path)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, true, state.IsUpToDate("a.proto", "x"))
+
+    assert.Equal(t, nil, ioutil.WriteFile(output, []byte("edited"), 0644))
+    assert.Equal(t, false, state.IsUpToDate("a.proto", "x"))
+    assert.Equal(t, nil, os.Remove(output))
+    assert.Equal(t, false, state.IsUpToDate("a.proto", "x"))
+}
+
+func TestGenerator_GenerateFiles(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("the fake protoc is a shell script")
+    }
+    dir, err := ioutil.TempDir("", "gos-proto")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    // the fake protoc writes user.pb.go into the output folder and counts its invocations
+    calls := filepath.Join(dir, "calls")
+    protoc := filepath.Join(dir, "protoc")
+    script := "#!/bin/sh\necho >> " + calls + "\nfor arg; do case $arg in --go_out=*) out=${arg#*=}; out=${out##*:}; echo generated > $out/user.pb.go;; esac; done\n"
+    assert.Equal(t, nil, ioutil.WriteFile(protoc, []byte(script), 0755))
+    defer func(path string) {
+        ProtocBinaryPath = path
+    }(ProtocBinaryPath)
+    ProtocBinaryPath = protoc
+
+    proto := filepath.Join(dir, "user.proto")
+    assert.Equal(t, nil, ioutil.WriteFile(proto, []byte("syntax = \"proto3\";\n"), 0644))
+    state, err := LoadState(filepath.Join(dir, DefaultStateFile))
+    assert.Equal(t, nil, err)
+    g := &Generator{
+        Config: &Config{Plugins: []*Plugin{{Name: "go"}}},
+        State:  state,
+    }
+    generated := filepath.Join(dir, "user.pb.go")
+    count := func() int {
+        data, err := ioutil.ReadFile(calls)
+        assert.Equal(t, nil, err)
+        return len(data)
+    }
+
+    assert.Equal(t, nil, g.GenerateFiles([]string{proto}))
+    _, err = os.Stat(generated)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 1, count())
+
+    assert.Equal(t, nil, g.GenerateFiles([]string{proto}))
+    assert.Equal(t, 1, count())
+
+    assert.Equal(t, nil, os.Remove(generated))
+    assert.Equal(t, nil, g.GenerateFiles([]string{proto}))
+    _, err = os.Stat(generated)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, 2, count())
+}
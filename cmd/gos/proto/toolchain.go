@@ -0,0 +1,299 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "archive/zip"
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "runtime"
+    "strings"
+
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// EnvGosProtoHome is used to specify the folder of the managed toolchain
+const EnvGosProtoHome = "GOS_PROTO_HOME"
+
+// DefaultProtocMirror is the default address of protoc release archives
+const DefaultProtocMirror = "https://github.com/protocolbuffers/protobuf/releases/download"
+
+// Here defines a set of toolchain errors
+var (
+    ErrUnsupportedPlatform = errors.New("protoc does not provide release archive for " + runtime.GOOS + "/" + runtime.GOARCH)
+    ErrChecksumMismatch    = errors.New("checksum mismatch")
+    ErrChecksumMissing     = errors.New("no checksum is configured, add the sha256 of the archive to checksums or use --skip-checksum to print it")
+    ErrInvalidModule       = errors.New("invalid plugin module, expected path@version")
+)
+
+// Protoc defines the pinned version of protoc
+type Protoc struct {
+    // Version is the version of protoc, such as 3.20.3
+    Version string `json:"version"`
+    // Mirror is the address of release archives, the default is DefaultProtocMirror,
+    // {Mirror}/v{Version}/protoc-{Version}-{platform}.zip will be downloaded
+    Mirror string `json:"mirror,omitempty"`
+    // Checksums maps the platform such as linux-x86_64 to the sha256 of archive
+    Checksums map[string]string `json:"checksums,omitempty"`
+    // SkipChecksum is used to install the archive whose checksum is not configured,
+    // its sha256 is printed to be pinned, it is specified by --skip-checksum
+    SkipChecksum bool `json:"-"`
+}
+
+// protocPlatforms maps GOOS/GOARCH to the platform name of protoc release archives
+var protocPlatforms = map[string]string{
+    "linux/386":     "linux-x86_32",
+    "linux/amd64":   "linux-x86_64",
+    "linux/arm64":   "linux-aarch_64",
+    "linux/ppc64le": "linux-ppcle_64",
+    "linux/s390x":   "linux-s390_64",
+    "darwin/amd64":  "osx-x86_64",
+    "darwin/arm64":  "osx-aarch_64",
+    "windows/386":   "win32",
+    "windows/amd64": "win64",
+}
+
+// GetToolchainDir is used to get the folder of the managed toolchain
+func GetToolchainDir() (string, error) {
+    if dir := os.Getenv(EnvGosProtoHome); dir != "" {
+        return dir, nil
+    }
+    cache, err := os.UserCacheDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(cache, "gos", "proto"), nil
+}
+
+// InstallToolchain is used to install the pinned protoc and plugins,
+// ProtocBinaryPath and the paths of plugins will be pointed to the installed binaries
+func (c *Config) InstallToolchain() error {
+    if !c.hasToolchain() {
+        return nil
+    }
+    dir, err := GetToolchainDir()
+    if err != nil {
+        return err
+    }
+    if c.Protoc != nil {
+        protoc, err := c.Protoc.Install(dir)
+        if err != nil {
+            return err
+        }
+        ProtocBinaryPath = protoc
+    }
+    for _, plugin := range c.Plugins {
+        if plugin.Module == "" || plugin.Path != "" {
+            continue
+        }
+        path, err := plugin.Install(dir)
+        if err != nil {
+            return err
+        }
+        plugin.Path = path
+    }
+    return nil
+}
+
+func (c *Config) hasToolchain() bool {
+    if c.Protoc != nil {
+        return true
+    }
+    for _, plugin := range c.Plugins {
+        if plugin.Module != "" && plugin.Path == "" {
+            return true
+        }
+    }
+    return false
+}
+
+// GetArchiveName is used to get the name of the release archive for current platform
+func (p *Protoc) GetArchiveName() (string, string, error) {
+    platform, ok := protocPlatforms[runtime.GOOS+"/"+runtime.GOARCH]
+    if !ok {
+        return "", "", ErrUnsupportedPlatform
+    }
+    return fmt.Sprintf("protoc-%s-%s.zip", p.Version, platform), platform, nil
+}
+
+// Install is used to download and extract protoc, it returns the path of protoc binary
+func (p *Protoc) Install(dir string) (string, error) {
+    root := filepath.Join(dir, "protoc", p.Version)
+    binary := filepath.Join(root, "bin", "protoc"+exeSuffix())
+    if _, err := os.Stat(binary); err == nil {
+        return binary, nil
+    }
+
+    name, platform, err := p.GetArchiveName()
+    if err != nil {
+        return "", err
+    }
+    mirror := p.Mirror
+    if mirror == "" {
+        mirror = DefaultProtocMirror
+    }
+    addr := fmt.Sprintf("%s/v%s/%s", strings.TrimRight(mirror, "/"), p.Version, name)
+    log.Println("downloading", addr)
+    data, err := download(addr)
+    if err != nil {
+        return "", err
+    }
+
+    sum := sha256.Sum256(data)
+    actual := hex.EncodeToString(sum[:])
+    expected, ok := p.Checksums[platform]
+    switch {
+    case ok && !strings.EqualFold(expected, actual):
+        return "", fmt.Errorf("%s: %s, expected %s, got %s", name, ErrChecksumMismatch, expected, actual)
+    case !ok && !p.SkipChecksum:
+        return "", fmt.Errorf("%s: %s", name, ErrChecksumMissing)
+    case !ok:
+        log.Printf("the checksum of %s is skipped, pin it by \"checksums\": {\"%s\": \"%s\"}", name, platform, actual)
+    }
+
+    tmp := root + ".tmp"
+    os.RemoveAll(tmp)
+    if err := unzip(data, tmp); err != nil {
+        os.RemoveAll(tmp)
+        return "", err
+    }
+    if err := os.Chmod(filepath.Join(tmp, "bin", "protoc"+exeSuffix()), 0755); err != nil {
+        os.RemoveAll(tmp)
+        return "", fmt.Errorf("%s: %s", name, err)
+    }
+    os.RemoveAll(root)
+    if err := os.Rename(tmp, root); err != nil {
+        return "", err
+    }
+    return binary, nil
+}
+
+// GetBinDir is used to get the folder of the plugin installed by go install
+func (p *Plugin) GetBinDir(dir string) (string, error) {
+    i := strings.LastIndex(p.Module, "@")
+    if i <= 0 || i == len(p.Module)-1 {
+        return "", ErrInvalidModule
+    }
+    escaped := strings.NewReplacer("/", "_", "@", "_", ":", "_").Replace(p.Module)
+    return filepath.Join(dir, "plugins", escaped), nil
+}
+
+// GetBinaryName is used to get the binary name of the plugin module,
+// which is the last element of package path excluding the major version suffix
+func (p *Plugin) GetBinaryName() string {
+    pkg := p.Module
+    if i := strings.LastIndex(pkg, "@"); i != -1 {
+        pkg = pkg[:i]
+    }
+    name := path.Base(pkg)
+    if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+        name = path.Base(path.Dir(pkg))
+    }
+    return name
+}
+
+// Install is used to install the plugin module by go install through the gos proxy,
+// it returns the path of plugin binary
+func (p *Plugin) Install(dir string) (string, error) {
+    bin, err := p.GetBinDir(dir)
+    if err != nil {
+        return "", err
+    }
+    binary := filepath.Join(bin, p.GetBinaryName()+exeSuffix())
+    if _, err := os.Stat(binary); err == nil {
+        return binary, nil
+    }
+
+    log.Println("installing", p.Module)
+    stderr := &bytes.Buffer{}
+    fd := util.GetGoBinaryCMD("install", []string{p.Module})
+    fd.Env = append(util.GetEnvWithLocalProxy(), "GOBIN="+bin, "GO111MODULE=on")
+    fd.Stdout = ioutil.Discard
+    fd.Stderr = stderr
+    if err := fd.Run(); err != nil {
+        return "", fmt.Errorf("%s: %s", p.Module, stderr.String())
+    }
+    return binary, nil
+}
+
+func download(addr string) ([]byte, error) {
+    r, err := http.Get(addr)
+    if err != nil {
+        return nil, err
+    }
+    defer r.Body.Close()
+    if r.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("%s: %s", addr, r.Status)
+    }
+    return ioutil.ReadAll(r.Body)
+}
+
+func unzip(data []byte, dir string) error {
+    reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        return err
+    }
+    for _, file := range reader.File {
+        path := filepath.Join(dir, filepath.FromSlash(file.Name))
+        if !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+            return fmt.Errorf("illegal file path in archive: %s", file.Name)
+        }
+        if file.FileInfo().IsDir() {
+            if err := os.MkdirAll(path, os.ModePerm); err != nil {
+                return err
+            }
+            continue
+        }
+        if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+            return err
+        }
+        if err := extractFile(file, path); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func extractFile(file *zip.File, path string) error {
+    src, err := file.Open()
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+    dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode()|0600)
+    if err != nil {
+        return err
+    }
+    defer dst.Close()
+    _, err = io.Copy(dst, src)
+    return err
+}
+
+func exeSuffix() string {
+    if runtime.GOOS == "windows" {
+        return ".exe"
+    }
+    return ""
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "archive/zip"
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func newFakeProtocArchive(t *testing.T) []byte {
+    buf := &bytes.Buffer{}
+    w := zip.NewWriter(buf)
+    for name, content := range map[string]string{
+        "bin/protoc" + exeSuffix():            "#!/bin/sh\n",
+        "include/google/protobuf/empty.proto": "syntax = \"proto3\";\n",
+    } {
+        f, err := w.Create(name)
+        assert.Equal(t, nil, err)
+        _, err = f.Write([]byte(content))
+        assert.Equal(t, nil, err)
+    }
+    assert.Equal(t, nil, w.Close())
+    return buf.Bytes()
+}
+
+func TestProtoc_Install(t *testing.T) {
+    protoc := &Protoc{Version: "3.20.3"}
+    name, platform, err := protoc.GetArchiveName()
+    if err == ErrUnsupportedPlatform {
+        t.Skip(err)
+    }
+
+    archive := newFakeProtocArchive(t)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/v3.20.3/"+name {
+            http.NotFound(w, r)
+            return
+        }
+        w.Write(archive)
+    }))
+    defer server.Close()
+
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    protoc.Mirror = server.URL
+    _, err = protoc.Install(dir)
+    assert.Equal(t, true, err != nil && strings.Contains(err.Error(), ErrChecksumMissing.Error()))
+    _, err = os.Stat(filepath.Join(dir, "protoc", "3.20.3"))
+    assert.Equal(t, true, os.IsNotExist(err))
+
+    protoc.Checksums = map[string]string{platform: strings.Repeat("0", 64)}
+    _, err = protoc.Install(dir)
+    assert.Equal(t, true, err != nil && strings.Contains(err.Error(), ErrChecksumMismatch.Error()))
+
+    sum := sha256.Sum256(archive)
+    protoc.Checksums[platform] = hex.EncodeToString(sum[:])
+    binary, err := protoc.Install(dir)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, filepath.Join(dir, "protoc", "3.20.3", "bin", "protoc"+exeSuffix()), binary)
+    _, err = os.Stat(filepath.Join(dir, "protoc", "3.20.3", "include", "google", "protobuf", "empty.proto"))
+    assert.Equal(t, nil, err)
+
+    protoc.Version = "3.0.0"
+    _, err = protoc.Install(dir)
+    assert.Equal(t, true, err != nil)
+}
+
+func TestProtoc_InstallSkipChecksum(t *testing.T) {
+    protoc := &Protoc{Version: "3.20.3", SkipChecksum: true}
+    if _, _, err := protoc.GetArchiveName(); err == ErrUnsupportedPlatform {
+        t.Skip(err)
+    }
+    archive := newFakeProtocArchive(t)
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Write(archive)
+    }))
+    defer server.Close()
+
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    assert.Equal(t, nil, err)
+    defer os.RemoveAll(dir)
+
+    protoc.Mirror = server.URL
+    _, err = protoc.Install(dir)
+    assert.Equal(t, nil, err)
+}
+
+func TestPlugin_GetBinaryName(t *testing.T) {
+    for module, want := range map[string]string{
+        "google.golang.org/protobuf/cmd/protoc-gen-go@v1.28.1":                      "protoc-gen-go",
+        "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-grpc-gateway@v2.15.0": "protoc-gen-grpc-gateway",
+        "github.com/example/protoc-gen-foo/v2@v2.0.0":                               "protoc-gen-foo",
+    } {
+        assert.Equal(t, want, (&Plugin{Module: module}).GetBinaryName(), module)
+    }
+}
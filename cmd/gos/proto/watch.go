@@ -0,0 +1,118 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Snapshot records the size and modification time of proto files
+type Snapshot map[string]string
+
+// TakeSnapshot is used to get the snapshot of files, the missing files are ignored
+func TakeSnapshot(files []string) Snapshot {
+    snapshot := Snapshot{}
+    for _, file := range files {
+        info, err := os.Stat(file)
+        if err != nil {
+            continue
+        }
+        snapshot[file] = fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+    }
+    return snapshot
+}
+
+// Changed is used to get the files which are added, modified or removed since s
+func (s Snapshot) Changed(current Snapshot) []string {
+    var changed []string
+    for file, stat := range current {
+        if s[file] != stat {
+            changed = append(changed, file)
+        }
+    }
+    for file := range s {
+        if _, ok := current[file]; !ok {
+            changed = append(changed, file)
+        }
+    }
+    return changed
+}
+
+// Watch is used to poll the proto files found by find and regenerate them when they change.
+// Only the changed groups and the groups importing them are regenerated since their
+// fingerprints change, it never returns
+func (g *Generator) Watch(find func() ([]string, error), interval time.Duration) {
+    if g.State == nil {
+        g.State = &State{Groups: map[string]*GroupState{}}
+    }
+    var last Snapshot
+    for ; ; time.Sleep(interval) {
+        files, err := find()
+        if err != nil {
+            log.Println(err)
+            continue
+        }
+        current := TakeSnapshot(files)
+        if last != nil {
+            changed := last.Changed(current)
+            if len(changed) == 0 {
+                continue
+            }
+            log.Printf("changed: %s", strings.Join(relativePaths(changed), ", "))
+        }
+        last = current
+
+        if err := g.GenerateFiles(files); err != nil {
+            log.Println(err)
+        }
+        if g.State.path != "" {
+            if err := g.State.Save(); err != nil {
+                log.Println(err)
+            }
+        }
+        // generation is finished, only the changes made later are interesting
+        g.Force = false
+        log.Println("watching for changes...")
+    }
+}
+
+// GetRelativeNames is used to get the files of group relative to the current folder
+func GetRelativeNames(group *Group) string {
+    var paths []string
+    for _, file := range group.Files {
+        paths = append(paths, filepath.Join(group.Dir, file))
+    }
+    return strings.Join(relativePaths(paths), ", ")
+}
+
+func relativePaths(paths []string) []string {
+    wd, err := os.Getwd()
+    if err != nil {
+        return paths
+    }
+    var r []string
+    for _, path := range paths {
+        if rel, err := filepath.Rel(wd, path); err == nil && filepath.IsAbs(path) {
+            path = rel
+        }
+        r = append(r, path)
+    }
+    return r
+}
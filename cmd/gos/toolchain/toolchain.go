@@ -0,0 +1,181 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolchain
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+
+    "github.com/spf13/cobra"
+    "github.com/storyicon/gos/pkg/toolchain"
+    "github.com/storyicon/gos/pkg/util"
+)
+
+// CmdToolchain is the command line for managing the go toolchains
+var CmdToolchain = &cobra.Command{
+    Use:   "toolchain",
+    Short: "install and switch go toolchains per project",
+    Long: `
+Usage:
+    gos toolchain install <version>...
+    gos toolchain list [--remote]
+    gos toolchain use <version|local>
+
+    The go toolchains are downloaded as golang.org/toolchain modules through the gos proxy,
+    and verified against go.sum or the checksum database (GOSUMDB) before they are installed,
+    the answers of the checksum database are verified with its key like the go command.
+    The toolchain of a project is pinned by the .go-version file in the project folder,
+    gos uses the pinned toolchain for the go commands in the folder and its sub folders,
+    and installs it when it is not installed.
+    GOS_TOOLCHAIN=<version> selects a toolchain regardless of the pin file,
+    GOS_TOOLCHAIN=local selects the go binary in $PATH.
+
+    <version> the go version such as 1.21.0 or go1.21.0,
+    a version without the patch number such as 1.22 is resolved to its latest release
+
+    - Install go1.21.0 and the latest go1.22
+    gos toolchain install 1.21.0 1.22
+
+    - Pin the current project to go1.21.0
+    gos toolchain use 1.21.0
+
+    - List the toolchains which can be installed
+    gos toolchain list --remote
+`,
+}
+
+// CmdInstall is the command line for installing go toolchains
+var CmdInstall = &cobra.Command{
+    Use:   "install <version>...",
+    Short: "install go toolchains",
+    Args:  cobra.MinimumNArgs(1),
+}
+
+// CmdList is the command line for listing go toolchains
+var CmdList = &cobra.Command{
+    Use:   "list",
+    Short: "list the installed go toolchains",
+    Args:  cobra.NoArgs,
+}
+
+// CmdUse is the command line for pinning the go toolchain of the current folder
+var CmdUse = &cobra.Command{
+    Use:   "use <version|local>",
+    Short: "pin the go toolchain of the current folder",
+    Args:  cobra.ExactArgs(1),
+}
+
+func init() {
+    CmdInstall.Run = RunInstall
+    CmdList.Run = RunList
+    CmdUse.Run = RunUse
+    CmdList.Flags().Bool("remote", false, "list the toolchains which can be installed for the current platform")
+    CmdToolchain.AddCommand(CmdInstall, CmdList, CmdUse)
+}
+
+// Install is used to resolve the version through the local proxy and install it if it is not installed,
+// it returns the full version
+func Install(version string) (string, error) {
+    version, err := toolchain.Normalize(version)
+    if err != nil {
+        return "", err
+    }
+    if toolchain.IsInstalled(version) {
+        return version, nil
+    }
+    proxy, err := util.GetLocalProxyURL()
+    if err != nil {
+        return "", err
+    }
+    version, err = toolchain.Resolve(proxy, version)
+    if err != nil {
+        return "", err
+    }
+    if toolchain.IsInstalled(version) {
+        return version, nil
+    }
+    log.Printf("installing %s into %s", version, toolchain.Dir(version))
+    return version, toolchain.Install(proxy, version)
+}
+
+// RunInstall is used to execute the install command
+func RunInstall(cmd *cobra.Command, args []string) {
+    for _, arg := range args {
+        version, err := Install(arg)
+        if err != nil {
+            log.Println(err)
+            os.Exit(1)
+            return
+        }
+        log.Printf("%s is installed", version)
+    }
+}
+
+// RunList is used to execute the list command
+func RunList(cmd *cobra.Command, args []string) {
+    var versions []string
+    var err error
+    if remote, _ := cmd.Flags().GetBool("remote"); remote {
+        var proxy string
+        if proxy, err = util.GetLocalProxyURL(); err == nil {
+            versions, err = toolchain.ListRemote(proxy)
+        }
+    } else {
+        versions, err = toolchain.Installed()
+    }
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    selected, _, _ := toolchain.Selected()
+    if installed, ok := toolchain.Lookup(selected); ok {
+        selected = installed
+    }
+    for _, version := range versions {
+        mark := " "
+        if version == selected {
+            mark = "*"
+        }
+        fmt.Printf("%s %s\n", mark, version)
+    }
+}
+
+// RunUse is used to execute the use command
+func RunUse(cmd *cobra.Command, args []string) {
+    if args[0] == toolchain.Local {
+        err := os.Remove(toolchain.PinFile)
+        if err != nil && !os.IsNotExist(err) {
+            log.Println(err)
+            os.Exit(1)
+            return
+        }
+        log.Printf("%s is removed, the go binary in $PATH is used", toolchain.PinFile)
+        return
+    }
+    version, err := Install(args[0])
+    if err == nil {
+        err = toolchain.WritePin(".", version)
+    }
+    if err != nil {
+        log.Println(err)
+        os.Exit(1)
+        return
+    }
+    path, _ := filepath.Abs(toolchain.PinFile)
+    log.Printf("%s is pinned by %s", version, path)
+}
@@ -16,6 +16,8 @@ package cmd
 
 import (
     "log"
+    "os"
+    "strings"
 
     "github.com/spf13/cobra"
     "github.com/storyicon/gos/cmd/go/build"
@@ -29,13 +31,16 @@ import (
     "github.com/storyicon/gos/cmd/go/install"
     "github.com/storyicon/gos/cmd/go/list"
     "github.com/storyicon/gos/cmd/go/mod"
+    "github.com/storyicon/gos/cmd/go/passthrough"
     "github.com/storyicon/gos/cmd/go/run"
     "github.com/storyicon/gos/cmd/go/test"
     "github.com/storyicon/gos/cmd/go/tool"
     "github.com/storyicon/gos/cmd/go/version"
     "github.com/storyicon/gos/cmd/go/vet"
     "github.com/storyicon/gos/cmd/gos/cross"
+    "github.com/storyicon/gos/cmd/gos/daemon"
     "github.com/storyicon/gos/cmd/gos/proto"
+    "github.com/storyicon/gos/cmd/gos/toolchain"
 )
 
 // CmdRoot is the root command
@@ -51,6 +56,9 @@ go get => gos get
 go build => gos build
 go ... => gos ...
 
+the go commands which gos does not wrap, such as gos work and gos bug,
+are passed through to your go binary, and gos help prints the help of go help.
+
 gos is compatible with all go commands and has go mod/get equipped with smart GOPROXY, 
 it automatically distinguishes between private and public repositories 
 and uses GOPROXY to download your lost package when appropriate.
@@ -58,7 +66,9 @@ and uses GOPROXY to download your lost package when appropriate.
 gos has a few extra commands to enhance your development experience:
 
   cross      agile and fast cross compiling
+  daemon     run the local proxy shared by gos processes
   proto      quick and easy compilation of proto files
+  toolchain  install and switch go toolchains per project
 
 You can use -h on these sub commands to get more information.
 `,
@@ -68,7 +78,8 @@ You can use -h on these sub commands to get more information.
 }
 
 func init() {
-    CmdRoot.AddCommand(
+    CmdRoot.SetHelpCommand(passthrough.CmdHelp)
+    CmdRoot.AddCommand(passthrough.Wrap(
         // Standard GO
         build.CmdBuild,
         clean.CmdClean,
@@ -86,9 +97,26 @@ func init() {
         tool.CmdTool,
         version.CmdVersion,
         vet.CmdVet,
-
+    )...)
+    CmdRoot.AddCommand(
         // GOS
         cross.CmdCross,
+        daemon.CmdDaemon,
         proto.CmdProto,
+        toolchain.CmdToolchain,
     )
 }
+
+// Execute is used to execute the command line,
+// the sub commands which gos does not wrap are passed through to the go binary
+func Execute() {
+    args := os.Args[1:]
+    if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+        CmdRoot.InitDefaultHelpCmd()
+        if _, _, err := CmdRoot.Find(args); err != nil {
+            passthrough.Run(args[0], args[1:])
+            return
+        }
+    }
+    CmdRoot.Execute()
+}
@@ -5,11 +5,14 @@ go 1.12
 require (
 	github.com/asaskevich/govalidator v0.0.0-20190424111038-f61b66f89f4a
 	github.com/gin-gonic/gin v1.4.0
+	github.com/golang/protobuf v1.3.1
 	github.com/hashicorp/go-multierror v1.0.0
 	github.com/json-iterator/go v1.1.7
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.5
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/mod v0.3.0
+	golang.org/x/sync v0.2.0
 )
 
 replace github.com/ugorji/go => github.com/ugorji/go v1.1.2-0.20180831062425-e253f1f20942
@@ -24,6 +24,8 @@ from now on, use gos instead of go:
     go run => gos run
     go ... => gos ...
 
+the go commands which gos does not wrap are passed through to the go binary.
+
 gos is compatible with all go commands and has go mod/get equipped with smart GOPROXY, it automatically distinguishes between private and public repositories and uses GOPROXY to download your lost package when appropriate.
 
 gos has a few extra commands to enhance your development experience:
@@ -40,10 +42,10 @@ package main
 
 import (
     "github.com/storyicon/gos/cmd"
-    "github.com/storyicon/gos/pkg/proxy"
+    // the local proxy is registered and started by the commands which resolve modules
+    _ "github.com/storyicon/gos/pkg/proxy"
 )
 
 func main() {
-    go proxy.Default().Run()
-    cmd.CmdRoot.Execute()
+    cmd.Execute()
 }
@@ -0,0 +1,452 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package daemon shares one local proxy between the concurrent gos processes of a user.
+// The daemon writes its address into a state file, the gos processes read the state file
+// to find it and start it in the background when it is not running
+package daemon
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/json-iterator/go"
+    "github.com/storyicon/gos/pkg/meta"
+)
+
+// The paths served by the daemon itself, module paths never start with a dot
+const (
+    PathState = "/.gos/state"
+    PathStop  = "/.gos/stop"
+)
+
+// HeaderToken is the header of the token which authorizes the requests to stop the daemon
+const HeaderToken = "X-Gos-Token"
+
+var (
+    // ErrRunning is returned when the daemon is started but another one is running
+    ErrRunning = errors.New("the gos daemon is already running")
+    // ErrNotRunning is returned when the daemon is not running and it can not be started
+    ErrNotRunning = errors.New("the gos daemon is not running")
+    // ErrTimeout is returned when the daemon is not ready in time
+    ErrTimeout = errors.New("timeout waiting for the gos daemon")
+    // ErrForbidden is returned when the daemon refuses to stop because the token is wrong
+    ErrForbidden = errors.New("the token of the gos daemon is wrong")
+)
+
+var (
+    // StateDir is the folder of the state files, it is private to the current user
+    StateDir = defaultStateDir()
+    // SpawnArgs returns the arguments of the current executable to run the daemon,
+    // the daemon is not started in the background if it is nil
+    SpawnArgs func() []string
+    // DefaultIdle is the time after which the daemon exits if it receives no request,
+    // the processes which hold the daemon ping it, so it does not exit while they run
+    DefaultIdle = 10 * time.Minute
+    // StartTimeout is the time to wait for the daemon started in the background
+    StartTimeout = 10 * time.Second
+    // LockTimeout is the time after which the lock of a crashed process is removed
+    LockTimeout = 15 * time.Second
+)
+
+func defaultStateDir() string {
+    if dir, err := os.UserCacheDir(); err == nil {
+        return filepath.Join(dir, "gos")
+    }
+    return filepath.Join(os.TempDir(), fmt.Sprintf(".gos-%d", os.Getuid()))
+}
+
+// Enabled is used to determine whether the daemon is used, it is disabled by GOS_DAEMON=off
+func Enabled() bool {
+    switch strings.ToLower(os.Getenv(meta.EnvGosDaemon)) {
+    case "off", "0", "false", "no":
+        return false
+    }
+    return true
+}
+
+// DefaultKey is the key of the daemon for the current executable and config,
+// the processes with different go binaries or upstreams do not share a daemon
+func DefaultKey() string {
+    executable, _ := os.Executable()
+    config := meta.GetConfig()
+    return Key(executable, config.GoBinaryPath, config.UpstreamAddr)
+}
+
+// Key is used to get a short key which identifies the values
+func Key(values ...string) string {
+    sum := sha256.Sum256([]byte(strings.Join(values, "\x00")))
+    return hex.EncodeToString(sum[:])[:12]
+}
+
+// StatePath is used to get the path of the state file of the daemon
+func StatePath(key string) string {
+    return filepath.Join(StateDir, "proxy-"+key+".json")
+}
+
+// State is the state of a running daemon, the token is only written into the state file,
+// which is private to the current user, and is required to stop the daemon
+type State struct {
+    PID       int       `json:"pid"`
+    Addr      string    `json:"addr"`
+    StartedAt time.Time `json:"started_at"`
+    Token     string    `json:"token,omitempty"`
+    // Idle is the time after which the daemon exits if it receives no request
+    Idle time.Duration `json:"idle"`
+}
+
+// ReadState is used to read the state file
+func ReadState(path string) (*State, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    state := &State{}
+    if err := jsoniter.Unmarshal(data, state); err != nil {
+        return nil, err
+    }
+    return state, nil
+}
+
+// Ping is used to check that the daemon of the state is serving,
+// a ping counts as activity so the daemon does not exit right after it
+func (s *State) Ping() error {
+    client := &http.Client{Timeout: time.Second}
+    resp, err := client.Get("http://" + s.Addr + PathState)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    current := &State{}
+    if err := jsoniter.NewDecoder(resp.Body).Decode(current); err != nil {
+        return err
+    }
+    if current.PID != s.PID {
+        return fmt.Errorf("%s is served by another process", s.Addr)
+    }
+    return nil
+}
+
+// Hold is used to keep the daemon alive while the current process runs,
+// the go commands started by the process use the daemon through GOPROXY at any time,
+// such as after a long compilation, so the daemon is pinged before it becomes idle.
+// The returned function releases the daemon
+func (s *State) Hold() func() {
+    done := make(chan struct{})
+    var once sync.Once
+    release := func() {
+        once.Do(func() {
+            close(done)
+        })
+    }
+    if s.Idle <= 0 {
+        return release
+    }
+    interval := s.Idle / 3
+    if interval < 10*time.Millisecond {
+        interval = 10 * time.Millisecond
+    }
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                s.Ping()
+            case <-done:
+                return
+            }
+        }
+    }()
+    return release
+}
+
+// Stop is used to ask the daemon to exit after the requests in flight are finished
+func (s *State) Stop() error {
+    req, err := http.NewRequest(http.MethodPost, "http://"+s.Addr+PathStop, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set(HeaderToken, s.Token)
+    client := &http.Client{Timeout: time.Second}
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    resp.Body.Close()
+    if resp.StatusCode == http.StatusForbidden {
+        return ErrForbidden
+    }
+    return nil
+}
+
+// Find is used to find the running daemon of the key
+func Find(key string) (*State, error) {
+    state, err := ReadState(StatePath(key))
+    if err != nil {
+        return nil, ErrNotRunning
+    }
+    if err := state.Ping(); err != nil {
+        return nil, ErrNotRunning
+    }
+    return state, nil
+}
+
+// Connect is used to get the state of the running daemon of the key,
+// the daemon is started in the background by the first of the concurrent callers
+// and the others wait for it to be ready
+func Connect(key string, timeout time.Duration) (*State, error) {
+    if state, err := Find(key); err == nil {
+        return state, nil
+    }
+    if SpawnArgs == nil {
+        return nil, ErrNotRunning
+    }
+    if err := os.MkdirAll(StateDir, 0700); err != nil {
+        return nil, err
+    }
+    deadline := time.Now().Add(timeout)
+    lock := filepath.Join(StateDir, "proxy-"+key+".lock")
+    for {
+        if unlock, ok := tryLock(lock); ok {
+            defer unlock()
+            if state, err := Find(key); err == nil {
+                return state, nil
+            }
+            return spawn(key, deadline)
+        }
+        if state, err := Find(key); err == nil {
+            return state, nil
+        }
+        if time.Now().After(deadline) {
+            return nil, ErrTimeout
+        }
+        time.Sleep(50 * time.Millisecond)
+    }
+}
+
+// tryLock is used to create the lock file exclusively, the lock older than LockTimeout is removed
+func tryLock(path string) (func(), bool) {
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+    if err != nil {
+        if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > LockTimeout {
+            os.Remove(path)
+        }
+        return nil, false
+    }
+    fmt.Fprint(file, os.Getpid())
+    file.Close()
+    return func() { os.Remove(path) }, true
+}
+
+// spawn is used to start the daemon in the background and wait for it to be ready,
+// the output of the daemon is written into the log file next to the state file
+func spawn(key string, deadline time.Time) (*State, error) {
+    executable, err := os.Executable()
+    if err != nil {
+        return nil, err
+    }
+    logPath := filepath.Join(StateDir, "proxy-"+key+".log")
+    logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+    if err != nil {
+        return nil, err
+    }
+    defer logFile.Close()
+
+    fd := exec.Command(executable, SpawnArgs()...)
+    fd.Stdout = logFile
+    fd.Stderr = logFile
+    detach(fd)
+    if err := fd.Start(); err != nil {
+        return nil, err
+    }
+    exited := make(chan error, 1)
+    go func() {
+        exited <- fd.Wait()
+    }()
+    for {
+        if state, err := Find(key); err == nil {
+            return state, nil
+        }
+        select {
+        case err := <-exited:
+            return nil, fmt.Errorf("the gos daemon exited: %v, see %s", err, logPath)
+        case <-time.After(50 * time.Millisecond):
+        }
+        if time.Now().After(deadline) {
+            return nil, ErrTimeout
+        }
+    }
+}
+
+// activity tracks the requests of the daemon
+type activity struct {
+    mu       sync.Mutex
+    inflight int
+    last     time.Time
+}
+
+func (a *activity) begin() {
+    a.mu.Lock()
+    a.inflight++
+    a.last = time.Now()
+    a.mu.Unlock()
+}
+
+func (a *activity) end() {
+    a.mu.Lock()
+    a.inflight--
+    a.last = time.Now()
+    a.mu.Unlock()
+}
+
+// idle is used to get how long the daemon has no request in flight
+func (a *activity) idle() time.Duration {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if a.inflight != 0 {
+        return 0
+    }
+    return time.Since(a.last)
+}
+
+// Serve is used to run the daemon of the key with the handler on a local port,
+// it returns after the daemon has received no request for the idle duration or is stopped
+func Serve(key string, handler http.Handler, idle time.Duration) error {
+    if _, err := Find(key); err == nil {
+        return ErrRunning
+    }
+    if err := os.MkdirAll(StateDir, 0700); err != nil {
+        return err
+    }
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        return err
+    }
+    token, err := newToken()
+    if err != nil {
+        ln.Close()
+        return err
+    }
+    state := &State{PID: os.Getpid(), Addr: ln.Addr().String(), StartedAt: time.Now(), Token: token, Idle: idle}
+    public := *state
+    public.Token = ""
+    path := StatePath(key)
+
+    a := &activity{last: time.Now()}
+    server := &http.Server{}
+    var once sync.Once
+    stopped := make(chan struct{})
+    stop := func() {
+        once.Do(func() {
+            removeState(path, state.PID)
+            go func() {
+                server.Shutdown(context.Background())
+                close(stopped)
+            }()
+        })
+    }
+    server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        a.begin()
+        defer a.end()
+        switch r.URL.Path {
+        case PathState:
+            jsoniter.NewEncoder(w).Encode(&public)
+        case PathStop:
+            if subtle.ConstantTimeCompare([]byte(r.Header.Get(HeaderToken)), []byte(token)) != 1 {
+                w.WriteHeader(http.StatusForbidden)
+                return
+            }
+            stop()
+        default:
+            handler.ServeHTTP(w, r)
+        }
+    })
+
+    if err := writeState(path, state); err != nil {
+        ln.Close()
+        return err
+    }
+    ticker := time.NewTicker(checkInterval(idle))
+    defer ticker.Stop()
+    go func() {
+        for range ticker.C {
+            if a.idle() >= idle {
+                stop()
+                return
+            }
+        }
+    }()
+
+    if err := server.Serve(ln); err != http.ErrServerClosed {
+        removeState(path, state.PID)
+        return err
+    }
+    <-stopped
+    return nil
+}
+
+func checkInterval(idle time.Duration) time.Duration {
+    interval := idle / 10
+    if interval < 10*time.Millisecond {
+        interval = 10 * time.Millisecond
+    }
+    if interval > 10*time.Second {
+        interval = 10 * time.Second
+    }
+    return interval
+}
+
+// newToken is used to generate a random token of the daemon
+func newToken() (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
+
+// writeState is used to replace the state file atomically, so that a partial file is never read
+func writeState(path string, state *State) error {
+    data, err := jsoniter.Marshal(state)
+    if err != nil {
+        return err
+    }
+    tmp := fmt.Sprintf("%s.%d.tmp", path, state.PID)
+    if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path)
+}
+
+// removeState is used to remove the state file if it still belongs to the process
+func removeState(path string, pid int) {
+    if state, err := ReadState(path); err == nil && state.PID == pid {
+        os.Remove(path)
+    }
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+    "io/ioutil"
+    "net/http"
+    "os"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-daemon")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    StateDir = dir
+
+    key := Key("test")
+    _, err = Connect(key, time.Second)
+    assert.Equal(t, ErrNotRunning, err)
+
+    handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte("proxy"))
+    })
+    done := make(chan error, 1)
+    go func() {
+        done <- Serve(key, handler, 300*time.Millisecond)
+    }()
+
+    var addr string
+    for i := 0; i < 100 && addr == ""; i++ {
+        if state, err := Connect(key, time.Second); err == nil {
+            addr = state.Addr
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    if !assert.NotEmpty(t, addr) {
+        return
+    }
+    resp, err := http.Get("http://" + addr + "/github.com/pkg/errors/@v/list")
+    if assert.NoError(t, err) {
+        body, _ := ioutil.ReadAll(resp.Body)
+        resp.Body.Close()
+        assert.Equal(t, "proxy", string(body))
+    }
+    assert.Equal(t, ErrRunning, Serve(key, handler, time.Second))
+
+    select {
+    case err := <-done:
+        assert.NoError(t, err)
+    case <-time.After(5 * time.Second):
+        t.Fatal("the daemon does not exit when it is idle")
+    }
+    _, err = os.Stat(StatePath(key))
+    assert.True(t, os.IsNotExist(err))
+    _, err = Find(key)
+    assert.Equal(t, ErrNotRunning, err)
+}
+
+func TestStop(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-daemon")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    StateDir = dir
+
+    key := Key("stop")
+    done := make(chan error, 1)
+    go func() {
+        done <- Serve(key, http.NotFoundHandler(), time.Minute)
+    }()
+    var state *State
+    for i := 0; i < 100 && state == nil; i++ {
+        state, _ = Find(key)
+        time.Sleep(10 * time.Millisecond)
+    }
+    if !assert.NotNil(t, state) {
+        return
+    }
+    assert.NotEmpty(t, state.Token)
+
+    // the token is not served to the processes which can not read the state file
+    resp, err := http.Get("http://" + state.Addr + PathState)
+    if assert.NoError(t, err) {
+        body, _ := ioutil.ReadAll(resp.Body)
+        resp.Body.Close()
+        assert.NotContains(t, string(body), state.Token)
+    }
+    forged := *state
+    forged.Token = "forged"
+    assert.Equal(t, ErrForbidden, forged.Stop())
+    assert.NoError(t, state.Ping())
+
+    assert.NoError(t, state.Stop())
+    select {
+    case err := <-done:
+        assert.NoError(t, err)
+    case <-time.After(5 * time.Second):
+        t.Fatal("the daemon does not exit when it is stopped")
+    }
+}
+
+func TestHold(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-daemon")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    StateDir = dir
+
+    key := Key("hold")
+    done := make(chan error, 1)
+    go func() {
+        done <- Serve(key, http.NotFoundHandler(), 200*time.Millisecond)
+    }()
+    var state *State
+    for i := 0; i < 100 && state == nil; i++ {
+        state, _ = Connect(key, time.Second)
+        time.Sleep(10 * time.Millisecond)
+    }
+    if !assert.NotNil(t, state) {
+        return
+    }
+    assert.Equal(t, 200*time.Millisecond, state.Idle)
+
+    // the daemon is held without other requests, such as during a long compilation
+    release := state.Hold()
+    select {
+    case <-done:
+        t.Fatal("the daemon exits while it is held")
+    case <-time.After(time.Second):
+    }
+    release()
+    select {
+    case err := <-done:
+        assert.NoError(t, err)
+    case <-time.After(5 * time.Second):
+        t.Fatal("the daemon does not exit after it is released")
+    }
+}
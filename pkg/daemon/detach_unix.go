@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+    "os/exec"
+    "syscall"
+)
+
+// detach starts the daemon in a new session, so that it is not killed with the terminal of gos
+func detach(fd *exec.Cmd) {
+    fd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
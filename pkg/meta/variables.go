@@ -15,8 +15,9 @@
 package meta
 
 import (
-    "net"
+    "errors"
     "os"
+    "sync"
     "sync/atomic"
 
     log "github.com/sirupsen/logrus"
@@ -26,13 +27,15 @@ import (
 const (
     EnvGosUpstreamAddress = "GOS_UPSTREAM_ADDRESS"
     EnvGosDebug           = "GOS_DEBUG"
+    EnvGosDaemon          = "GOS_DAEMON"
+    EnvGosToolchain       = "GOS_TOOLCHAIN"
 )
 
 var v atomic.Value
 
 var defaultSysVar = SystemVar{
     GoBinaryPath:    "go",
-    ProxyListenAddr: "",
+    ProxyListenAddr: "127.0.0.1:0",
     UpstreamAddr:    "https://athens.azurefd.net",
 }
 
@@ -73,19 +76,52 @@ func GetGoBinaryPath() string {
     return config.GoBinaryPath
 }
 
-// GetLocalProxyListenAddr is used to get the ProxyListenAddr currently configured
+// GetLocalProxyListenAddr is used to get the ProxyListenAddr currently configured,
+// it is the actual address after the local proxy is started
 func GetLocalProxyListenAddr() string {
     config := GetConfig()
     return config.ProxyListenAddr
 }
 
-func init() {
-    address, err := allocateAddr()
-    if err != nil {
-        panic(err)
-    }
-    defaultSysVar.ProxyListenAddr = address
+// ErrNoProxyStarter is returned when the local proxy is started but no ProxyStarter is registered
+var ErrNoProxyStarter = errors.New("the local proxy is not registered")
+
+// ProxyStarter starts the local proxy on the listen address and returns the address it is bound to,
+// the proxy must be accepting connections when it returns
+type ProxyStarter func(addr string) (string, error)
+
+var (
+    proxyStarter ProxyStarter
+    proxyOnce    sync.Once
+    proxyErr     error
+)
+
+// RegisterProxyStarter is used to register the function which starts the local proxy
+func RegisterProxyStarter(starter ProxyStarter) {
+    proxyStarter = starter
+}
 
+// StartLocalProxy is used to start the local proxy on the first call and get its address,
+// the later calls wait for the first one and return the same result
+func StartLocalProxy() (string, error) {
+    proxyOnce.Do(func() {
+        if proxyStarter == nil {
+            proxyErr = ErrNoProxyStarter
+            return
+        }
+        addr, err := proxyStarter(GetLocalProxyListenAddr())
+        if err != nil {
+            proxyErr = err
+            return
+        }
+        config := GetConfig()
+        config.ProxyListenAddr = addr
+        LoadConfig(config)
+    })
+    return GetLocalProxyListenAddr(), proxyErr
+}
+
+func init() {
     upstream := os.Getenv(EnvGosUpstreamAddress)
     if upstream != "" {
         defaultSysVar.UpstreamAddr = upstream
@@ -99,12 +135,3 @@ func init() {
 
     LoadConfig(defaultSysVar)
 }
-
-func allocateAddr() (string, error) {
-    ln, err := net.Listen("tcp", ":0")
-    if err != nil {
-        return "", err
-    }
-    defer ln.Close()
-    return ln.Addr().String(), nil
-}
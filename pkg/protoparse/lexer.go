@@ -0,0 +1,192 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protoparse
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Here defines the kinds of token
+const (
+    tokenEOF = iota
+    tokenIdent
+    tokenNumber
+    tokenString
+    tokenSymbol
+)
+
+type token struct {
+    kind   int
+    text   string
+    line   int
+    column int
+}
+
+func (t token) String() string {
+    if t.kind == tokenEOF {
+        return "EOF"
+    }
+    return fmt.Sprintf("%q", t.text)
+}
+
+type lexer struct {
+    src    []rune
+    pos    int
+    line   int
+    column int
+}
+
+func newLexer(src string) *lexer {
+    return &lexer{src: []rune(src), line: 1, column: 1}
+}
+
+func (l *lexer) peekRune(offset int) rune {
+    if l.pos+offset >= len(l.src) {
+        return 0
+    }
+    return l.src[l.pos+offset]
+}
+
+func (l *lexer) advance() rune {
+    r := l.src[l.pos]
+    l.pos++
+    if r == '\n' {
+        l.line++
+        l.column = 1
+    } else {
+        l.column++
+    }
+    return r
+}
+
+// skipSpace skips the white spaces and comments
+func (l *lexer) skipSpace() error {
+    for l.pos < len(l.src) {
+        r := l.peekRune(0)
+        switch {
+        case r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' || r == '\v':
+            l.advance()
+        case r == '/' && l.peekRune(1) == '/':
+            for l.pos < len(l.src) && l.peekRune(0) != '\n' {
+                l.advance()
+            }
+        case r == '/' && l.peekRune(1) == '*':
+            line, column := l.line, l.column
+            l.advance()
+            l.advance()
+            for {
+                if l.pos >= len(l.src) {
+                    return &Error{Line: line, Column: column, Message: "unterminated comment"}
+                }
+                if l.peekRune(0) == '*' && l.peekRune(1) == '/' {
+                    l.advance()
+                    l.advance()
+                    break
+                }
+                l.advance()
+            }
+        default:
+            return nil
+        }
+    }
+    return nil
+}
+
+func (l *lexer) next() (token, error) {
+    if err := l.skipSpace(); err != nil {
+        return token{}, err
+    }
+    t := token{line: l.line, column: l.column}
+    if l.pos >= len(l.src) {
+        t.kind = tokenEOF
+        return t, nil
+    }
+    r := l.peekRune(0)
+    start := l.pos
+    switch {
+    case isLetter(r) || (r == '.' && isLetter(l.peekRune(1))):
+        t.kind = tokenIdent
+        l.advance()
+        for l.pos < len(l.src) {
+            c := l.peekRune(0)
+            if isLetter(c) || isDigit(c) || (c == '.' && isLetter(l.peekRune(1))) {
+                l.advance()
+                continue
+            }
+            break
+        }
+    case isDigit(r) || (r == '.' && isDigit(l.peekRune(1))):
+        t.kind = tokenNumber
+        for l.pos < len(l.src) {
+            c := l.peekRune(0)
+            if isLetter(c) || isDigit(c) || c == '.' {
+                l.advance()
+                continue
+            }
+            if (c == '+' || c == '-') && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E') && !strings.HasPrefix(strings.ToLower(string(l.src[start:l.pos])), "0x") {
+                l.advance()
+                continue
+            }
+            break
+        }
+    case r == '"' || r == '\'':
+        t.kind = tokenString
+        quote := l.advance()
+        var b strings.Builder
+        for {
+            if l.pos >= len(l.src) || l.peekRune(0) == '\n' {
+                return t, &Error{Line: t.line, Column: t.column, Message: "unterminated string"}
+            }
+            c := l.advance()
+            if c == quote {
+                break
+            }
+            if c == '\\' && l.pos < len(l.src) {
+                c = unescape(l.advance())
+            }
+            b.WriteRune(c)
+        }
+        t.text = b.String()
+        return t, nil
+    default:
+        t.kind = tokenSymbol
+        l.advance()
+    }
+    t.text = string(l.src[start:l.pos])
+    return t, nil
+}
+
+func unescape(r rune) rune {
+    switch r {
+    case 'n':
+        return '\n'
+    case 't':
+        return '\t'
+    case 'r':
+        return '\r'
+    case '0':
+        return 0
+    }
+    return r
+}
+
+func isLetter(r rune) bool {
+    return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isDigit(r rune) bool {
+    return r >= '0' && r <= '9'
+}
@@ -0,0 +1,828 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package protoparse is a lightweight parser of .proto files,
+// it keeps the declarations needed by linting and compatibility checks and skips the option values
+package protoparse
+
+import (
+    "fmt"
+    "io/ioutil"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Error is a syntax error of proto file
+type Error struct {
+    Filename string
+    Line     int
+    Column   int
+    Message  string
+}
+
+func (e *Error) Error() string {
+    return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Column, e.Message)
+}
+
+// Position is the location of a declaration in the proto file
+type Position struct {
+    Line   int
+    Column int
+}
+
+// File is the parsed proto file
+type File struct {
+    Name     string
+    Syntax   string
+    Package  string
+    Imports  []*Import
+    Options  map[string]string
+    Messages []*Message
+    Enums    []*Enum
+    Services []*Service
+    Position
+}
+
+// Import is an import statement
+type Import struct {
+    Path   string
+    Public bool
+    Weak   bool
+    Position
+}
+
+// Message is a message declaration
+type Message struct {
+    Name     string
+    Fields   []*Field
+    Messages []*Message
+    Enums    []*Enum
+    Reserved Reserved
+    Position
+}
+
+// Field is a field of message, the Type of map field is "map<key, value>"
+type Field struct {
+    Name   string
+    Type   string
+    Number int
+    // Label is one of "", "optional", "required" and "repeated"
+    Label string
+    // Oneof is the name of oneof containing the field
+    Oneof string
+    Position
+}
+
+// Enum is an enum declaration
+type Enum struct {
+    Name     string
+    Values   []*EnumValue
+    Reserved Reserved
+    Position
+}
+
+// EnumValue is a value of enum
+type EnumValue struct {
+    Name   string
+    Number int
+    Position
+}
+
+// Reserved is the reserved numbers and names of message or enum
+type Reserved struct {
+    Ranges [][2]int
+    Names  []string
+}
+
+// HasNumber is used to determine whether the number is reserved
+func (r Reserved) HasNumber(number int) bool {
+    for _, rng := range r.Ranges {
+        if number >= rng[0] && number <= rng[1] {
+            return true
+        }
+    }
+    return false
+}
+
+// HasName is used to determine whether the name is reserved
+func (r Reserved) HasName(name string) bool {
+    for _, n := range r.Names {
+        if n == name {
+            return true
+        }
+    }
+    return false
+}
+
+// Service is a service declaration
+type Service struct {
+    Name    string
+    Methods []*Method
+    Position
+}
+
+// Method is a rpc of service
+type Method struct {
+    Name            string
+    Input           string
+    Output          string
+    ClientStreaming bool
+    ServerStreaming bool
+    Position
+}
+
+// ParseFile is used to read and parse the proto file
+func ParseFile(path string) (*File, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    return Parse(path, string(data))
+}
+
+// Parse is used to parse the content of proto file, name is used in errors
+func Parse(name string, src string) (*File, error) {
+    p := &parser{lexer: newLexer(src)}
+    file, err := p.parseFile()
+    if err != nil {
+        if e, ok := err.(*Error); ok {
+            e.Filename = name
+        }
+        return nil, err
+    }
+    file.Name = name
+    return file, nil
+}
+
+type parser struct {
+    lexer  *lexer
+    peeked *token
+}
+
+func (p *parser) peek() (token, error) {
+    if p.peeked == nil {
+        t, err := p.lexer.next()
+        if err != nil {
+            return t, err
+        }
+        p.peeked = &t
+    }
+    return *p.peeked, nil
+}
+
+func (p *parser) next() (token, error) {
+    t, err := p.peek()
+    p.peeked = nil
+    return t, err
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+    return &Error{Line: t.line, Column: t.column, Message: fmt.Sprintf(format, args...)}
+}
+
+// expect is used to consume the token with the text
+func (p *parser) expect(text string) (token, error) {
+    t, err := p.next()
+    if err != nil {
+        return t, err
+    }
+    if t.text != text || t.kind == tokenString {
+        return t, p.errorf(t, "expected %q, found %s", text, t)
+    }
+    return t, nil
+}
+
+// accept is used to consume the token if its text matches
+func (p *parser) accept(text string) (bool, error) {
+    t, err := p.peek()
+    if err != nil {
+        return false, err
+    }
+    if t.text == text && t.kind != tokenString {
+        p.peeked = nil
+        return true, nil
+    }
+    return false, nil
+}
+
+func (p *parser) ident() (token, error) {
+    t, err := p.next()
+    if err != nil {
+        return t, err
+    }
+    if t.kind != tokenIdent {
+        return t, p.errorf(t, "expected identifier, found %s", t)
+    }
+    return t, nil
+}
+
+func (p *parser) str() (token, error) {
+    t, err := p.next()
+    if err != nil {
+        return t, err
+    }
+    if t.kind != tokenString {
+        return t, p.errorf(t, "expected string, found %s", t)
+    }
+    // adjacent strings are concatenated
+    for {
+        n, err := p.peek()
+        if err != nil {
+            return t, err
+        }
+        if n.kind != tokenString {
+            return t, nil
+        }
+        p.peeked = nil
+        t.text += n.text
+    }
+}
+
+func (p *parser) integer() (int, error) {
+    t, err := p.next()
+    if err != nil {
+        return 0, err
+    }
+    sign := 1
+    if t.text == "-" {
+        sign = -1
+        if t, err = p.next(); err != nil {
+            return 0, err
+        }
+    }
+    if t.kind != tokenNumber {
+        return 0, p.errorf(t, "expected number, found %s", t)
+    }
+    n, err := strconv.ParseInt(t.text, 0, 64)
+    if err != nil {
+        return 0, p.errorf(t, "invalid number %s", t)
+    }
+    return sign * int(n), nil
+}
+
+func position(t token) Position {
+    return Position{Line: t.line, Column: t.column}
+}
+
+func (p *parser) parseFile() (*File, error) {
+    file := &File{Options: map[string]string{}, Syntax: "proto2", Position: Position{Line: 1, Column: 1}}
+    for {
+        t, err := p.next()
+        if err != nil {
+            return nil, err
+        }
+        if t.kind == tokenEOF {
+            return file, nil
+        }
+        switch t.text {
+        case ";":
+        case "syntax", "edition":
+            if _, err := p.expect("="); err != nil {
+                return nil, err
+            }
+            value, err := p.str()
+            if err != nil {
+                return nil, err
+            }
+            file.Syntax = value.text
+            file.Position = position(t)
+            if _, err := p.expect(";"); err != nil {
+                return nil, err
+            }
+        case "package":
+            name, err := p.ident()
+            if err != nil {
+                return nil, err
+            }
+            file.Package = name.text
+            if _, err := p.expect(";"); err != nil {
+                return nil, err
+            }
+        case "import":
+            imp := &Import{Position: position(t)}
+            if imp.Public, err = p.accept("public"); err != nil {
+                return nil, err
+            }
+            if !imp.Public {
+                if imp.Weak, err = p.accept("weak"); err != nil {
+                    return nil, err
+                }
+            }
+            path, err := p.str()
+            if err != nil {
+                return nil, err
+            }
+            imp.Path = path.text
+            file.Imports = append(file.Imports, imp)
+            if _, err := p.expect(";"); err != nil {
+                return nil, err
+            }
+        case "option":
+            name, value, err := p.parseOption()
+            if err != nil {
+                return nil, err
+            }
+            file.Options[name] = value
+        case "message":
+            message, err := p.parseMessage(t)
+            if err != nil {
+                return nil, err
+            }
+            file.Messages = append(file.Messages, message)
+        case "enum":
+            enum, err := p.parseEnum(t)
+            if err != nil {
+                return nil, err
+            }
+            file.Enums = append(file.Enums, enum)
+        case "service":
+            service, err := p.parseService(t)
+            if err != nil {
+                return nil, err
+            }
+            file.Services = append(file.Services, service)
+        case "extend":
+            if err := p.skipDeclaration(); err != nil {
+                return nil, err
+            }
+        default:
+            return nil, p.errorf(t, "unexpected %s", t)
+        }
+    }
+}
+
+// parseOption is used to parse the rest of option statement after "option",
+// the value of message literal is returned as empty string
+func (p *parser) parseOption() (string, string, error) {
+    var name strings.Builder
+    for {
+        t, err := p.next()
+        if err != nil {
+            return "", "", err
+        }
+        if t.text == "=" && t.kind == tokenSymbol {
+            break
+        }
+        if t.kind == tokenEOF || t.text == ";" {
+            return "", "", p.errorf(t, "expected \"=\", found %s", t)
+        }
+        name.WriteString(t.text)
+    }
+    t, err := p.peek()
+    if err != nil {
+        return "", "", err
+    }
+    value := ""
+    switch {
+    case t.text == "{" && t.kind == tokenSymbol:
+        if err := p.skipBlock(); err != nil {
+            return "", "", err
+        }
+    case t.kind == tokenString:
+        s, err := p.str()
+        if err != nil {
+            return "", "", err
+        }
+        value = s.text
+    default:
+        p.next()
+        value = t.text
+        if t.text == "-" {
+            n, err := p.next()
+            if err != nil {
+                return "", "", err
+            }
+            value += n.text
+        }
+    }
+    if _, err := p.expect(";"); err != nil {
+        return "", "", err
+    }
+    return name.String(), value, nil
+}
+
+// skipBlock is used to skip the balanced braces or brackets starting at the next token
+func (p *parser) skipBlock() error {
+    open, err := p.next()
+    if err != nil {
+        return err
+    }
+    pairs := map[string]string{"{": "}", "[": "]", "(": ")"}
+    var stack []string
+    stack = append(stack, pairs[open.text])
+    for len(stack) != 0 {
+        t, err := p.next()
+        if err != nil {
+            return err
+        }
+        if t.kind == tokenEOF {
+            return p.errorf(t, "expected %q, found EOF", stack[len(stack)-1])
+        }
+        if t.kind != tokenSymbol {
+            continue
+        }
+        if closing, ok := pairs[t.text]; ok {
+            stack = append(stack, closing)
+        } else if t.text == stack[len(stack)-1] {
+            stack = stack[:len(stack)-1]
+        }
+    }
+    return nil
+}
+
+// skipDeclaration is used to skip a declaration ending with ";" or a block
+func (p *parser) skipDeclaration() error {
+    for {
+        t, err := p.peek()
+        if err != nil {
+            return err
+        }
+        switch {
+        case t.kind == tokenEOF:
+            return p.errorf(t, "unexpected EOF")
+        case t.kind == tokenSymbol && (t.text == "{" || t.text == "["):
+            if err := p.skipBlock(); err != nil {
+                return err
+            }
+            if t.text == "{" {
+                return nil
+            }
+        case t.kind == tokenSymbol && t.text == ";":
+            p.next()
+            return nil
+        default:
+            p.next()
+        }
+    }
+}
+
+// skipFieldOptions is used to skip the [...] options of field or enum value
+func (p *parser) skipFieldOptions() error {
+    t, err := p.peek()
+    if err != nil {
+        return err
+    }
+    if t.kind == tokenSymbol && t.text == "[" {
+        return p.skipBlock()
+    }
+    return nil
+}
+
+func (p *parser) parseMessage(start token) (*Message, error) {
+    name, err := p.ident()
+    if err != nil {
+        return nil, err
+    }
+    message := &Message{Name: name.text, Position: position(start)}
+    if _, err := p.expect("{"); err != nil {
+        return nil, err
+    }
+    if err := p.parseMessageBody(message, ""); err != nil {
+        return nil, err
+    }
+    return message, nil
+}
+
+// parseMessageBody is used to parse the declarations until "}", oneof is the name of enclosing oneof
+func (p *parser) parseMessageBody(message *Message, oneof string) error {
+    for {
+        t, err := p.peek()
+        if err != nil {
+            return err
+        }
+        if t.kind == tokenEOF {
+            return p.errorf(t, "expected \"}\", found EOF")
+        }
+        if t.kind == tokenSymbol {
+            p.next()
+            switch t.text {
+            case "}":
+                return nil
+            case ";":
+                continue
+            }
+            return p.errorf(t, "unexpected %s", t)
+        }
+        if t.kind != tokenIdent {
+            return p.errorf(t, "unexpected %s", t)
+        }
+        switch t.text {
+        case "message":
+            p.next()
+            nested, err := p.parseMessage(t)
+            if err != nil {
+                return err
+            }
+            message.Messages = append(message.Messages, nested)
+            continue
+        case "enum":
+            p.next()
+            enum, err := p.parseEnum(t)
+            if err != nil {
+                return err
+            }
+            message.Enums = append(message.Enums, enum)
+            continue
+        case "option":
+            p.next()
+            if _, _, err := p.parseOption(); err != nil {
+                return err
+            }
+            continue
+        case "extend", "extensions":
+            p.next()
+            if err := p.skipDeclaration(); err != nil {
+                return err
+            }
+            continue
+        case "reserved":
+            p.next()
+            if err := p.parseReserved(&message.Reserved); err != nil {
+                return err
+            }
+            continue
+        case "oneof":
+            p.next()
+            name, err := p.ident()
+            if err != nil {
+                return err
+            }
+            if _, err := p.expect("{"); err != nil {
+                return err
+            }
+            if err := p.parseMessageBody(message, name.text); err != nil {
+                return err
+            }
+            continue
+        }
+        if err := p.parseField(message, oneof); err != nil {
+            return err
+        }
+    }
+}
+
+func (p *parser) parseField(message *Message, oneof string) error {
+    start, err := p.next()
+    if err != nil {
+        return err
+    }
+    field := &Field{Oneof: oneof, Position: position(start)}
+    typ := start
+    switch start.text {
+    case "optional", "required", "repeated":
+        field.Label = start.text
+        if typ, err = p.ident(); err != nil {
+            return err
+        }
+    }
+    field.Type = typ.text
+    if typ.text == "map" {
+        if _, err := p.expect("<"); err != nil {
+            return err
+        }
+        key, err := p.ident()
+        if err != nil {
+            return err
+        }
+        if _, err := p.expect(","); err != nil {
+            return err
+        }
+        value, err := p.ident()
+        if err != nil {
+            return err
+        }
+        if _, err := p.expect(">"); err != nil {
+            return err
+        }
+        field.Type = fmt.Sprintf("map<%s, %s>", key.text, value.text)
+    }
+    name, err := p.ident()
+    if err != nil {
+        return err
+    }
+    field.Name = name.text
+    if _, err := p.expect("="); err != nil {
+        return err
+    }
+    if field.Number, err = p.integer(); err != nil {
+        return err
+    }
+    if err := p.skipFieldOptions(); err != nil {
+        return err
+    }
+    if typ.text == "group" {
+        // the group is a nested message and a field named in lower case
+        group := &Message{Name: field.Name, Position: field.Position}
+        field.Type = group.Name
+        field.Name = strings.ToLower(group.Name)
+        if _, err := p.expect("{"); err != nil {
+            return err
+        }
+        if err := p.parseMessageBody(group, ""); err != nil {
+            return err
+        }
+        message.Messages = append(message.Messages, group)
+        message.Fields = append(message.Fields, field)
+        return nil
+    }
+    message.Fields = append(message.Fields, field)
+    _, err = p.expect(";")
+    return err
+}
+
+// parseReserved is used to parse the rest of reserved statement after "reserved"
+func (p *parser) parseReserved(reserved *Reserved) error {
+    for {
+        t, err := p.peek()
+        if err != nil {
+            return err
+        }
+        switch t.kind {
+        case tokenString:
+            p.next()
+            reserved.Names = append(reserved.Names, t.text)
+        case tokenIdent:
+            p.next()
+            reserved.Names = append(reserved.Names, t.text)
+        default:
+            from, err := p.integer()
+            if err != nil {
+                return err
+            }
+            to := from
+            if ok, err := p.accept("to"); err != nil {
+                return err
+            } else if ok {
+                if ok, err := p.accept("max"); err != nil {
+                    return err
+                } else if ok {
+                    to = math.MaxInt32
+                } else if to, err = p.integer(); err != nil {
+                    return err
+                }
+            }
+            reserved.Ranges = append(reserved.Ranges, [2]int{from, to})
+        }
+        t, err = p.next()
+        if err != nil {
+            return err
+        }
+        if t.text == ";" && t.kind == tokenSymbol {
+            return nil
+        }
+        if t.text != "," || t.kind != tokenSymbol {
+            return p.errorf(t, "expected \",\" or \";\", found %s", t)
+        }
+    }
+}
+
+func (p *parser) parseEnum(start token) (*Enum, error) {
+    name, err := p.ident()
+    if err != nil {
+        return nil, err
+    }
+    enum := &Enum{Name: name.text, Position: position(start)}
+    if _, err := p.expect("{"); err != nil {
+        return nil, err
+    }
+    for {
+        t, err := p.next()
+        if err != nil {
+            return nil, err
+        }
+        switch {
+        case t.kind == tokenEOF:
+            return nil, p.errorf(t, "expected \"}\", found EOF")
+        case t.kind == tokenSymbol && t.text == "}":
+            return enum, nil
+        case t.kind == tokenSymbol && t.text == ";":
+        case t.kind == tokenIdent && t.text == "option":
+            if _, _, err := p.parseOption(); err != nil {
+                return nil, err
+            }
+        case t.kind == tokenIdent && t.text == "reserved":
+            if err := p.parseReserved(&enum.Reserved); err != nil {
+                return nil, err
+            }
+        case t.kind == tokenIdent:
+            value := &EnumValue{Name: t.text, Position: position(t)}
+            if _, err := p.expect("="); err != nil {
+                return nil, err
+            }
+            if value.Number, err = p.integer(); err != nil {
+                return nil, err
+            }
+            if err := p.skipFieldOptions(); err != nil {
+                return nil, err
+            }
+            if _, err := p.expect(";"); err != nil {
+                return nil, err
+            }
+            enum.Values = append(enum.Values, value)
+        default:
+            return nil, p.errorf(t, "unexpected %s", t)
+        }
+    }
+}
+
+func (p *parser) parseService(start token) (*Service, error) {
+    name, err := p.ident()
+    if err != nil {
+        return nil, err
+    }
+    service := &Service{Name: name.text, Position: position(start)}
+    if _, err := p.expect("{"); err != nil {
+        return nil, err
+    }
+    for {
+        t, err := p.next()
+        if err != nil {
+            return nil, err
+        }
+        switch {
+        case t.kind == tokenEOF:
+            return nil, p.errorf(t, "expected \"}\", found EOF")
+        case t.kind == tokenSymbol && t.text == "}":
+            return service, nil
+        case t.kind == tokenSymbol && t.text == ";":
+        case t.kind == tokenIdent && t.text == "option":
+            if _, _, err := p.parseOption(); err != nil {
+                return nil, err
+            }
+        case t.kind == tokenIdent && t.text == "rpc":
+            method, err := p.parseMethod(t)
+            if err != nil {
+                return nil, err
+            }
+            service.Methods = append(service.Methods, method)
+        default:
+            return nil, p.errorf(t, "unexpected %s", t)
+        }
+    }
+}
+
+func (p *parser) parseMethod(start token) (*Method, error) {
+    name, err := p.ident()
+    if err != nil {
+        return nil, err
+    }
+    method := &Method{Name: name.text, Position: position(start)}
+    if method.Input, method.ClientStreaming, err = p.parseMethodType(); err != nil {
+        return nil, err
+    }
+    if _, err := p.expect("returns"); err != nil {
+        return nil, err
+    }
+    if method.Output, method.ServerStreaming, err = p.parseMethodType(); err != nil {
+        return nil, err
+    }
+    t, err := p.peek()
+    if err != nil {
+        return nil, err
+    }
+    if t.kind == tokenSymbol && t.text == "{" {
+        return method, p.skipBlock()
+    }
+    _, err = p.expect(";")
+    return method, err
+}
+
+// parseMethodType is used to parse "(stream Type)"
+func (p *parser) parseMethodType() (string, bool, error) {
+    if _, err := p.expect("("); err != nil {
+        return "", false, err
+    }
+    typ, err := p.ident()
+    if err != nil {
+        return "", false, err
+    }
+    stream := false
+    if typ.text == "stream" {
+        t, err := p.peek()
+        if err != nil {
+            return "", false, err
+        }
+        if t.kind == tokenIdent {
+            stream = true
+            if typ, err = p.ident(); err != nil {
+                return "", false, err
+            }
+        }
+    }
+    if _, err := p.expect(")"); err != nil {
+        return "", false, err
+    }
+    return typ.text, stream, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protoparse
+
+import (
+    "math"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+const source = `
+// the user service
+syntax = "proto3";
+
+package example.user.v1;
+
+import "google/protobuf/timestamp.proto";
+import public "common.proto";
+import "google/api/annotations.proto";
+
+option go_package = "example.com/api/user/v1;userv1";
+option java_multiple_files = true;
+
+/* the user */
+message User {
+    reserved 4, 8 to 10, 100 to max;
+    reserved "password";
+
+    string name = 1 [(validate.rules).string = {min_len: 1, max_len: 64}];
+    repeated string tags = 2;
+    map<string, int64> scores = 3;
+    .google.protobuf.Timestamp created_at = 5;
+    oneof contact {
+        string email = 6;
+        string phone = 7 [deprecated = true];
+    }
+    Status status = 11;
+
+    enum Status {
+        option allow_alias = true;
+        STATUS_UNSPECIFIED = 0;
+        STATUS_ACTIVE = 1;
+        STATUS_DELETED = -1;
+        reserved 2;
+    }
+    message Address { string city = 1; }
+}
+
+service UserService {
+    option (google.api.default_host) = "user.example.com";
+    rpc GetUser(GetUserRequest) returns (User) {
+        option (google.api.http) = {get: "/v1/users/{name}"};
+    }
+    rpc Watch(stream WatchRequest) returns (stream User);
+}
+`
+
+func TestParse(t *testing.T) {
+    file, err := Parse("user.proto", source)
+    assert.Equal(t, nil, err)
+    assert.Equal(t, "proto3", file.Syntax)
+    assert.Equal(t, "example.user.v1", file.Package)
+    assert.Equal(t, "example.com/api/user/v1;userv1", file.Options["go_package"])
+    assert.Equal(t, "true", file.Options["java_multiple_files"])
+    assert.Equal(t, 3, len(file.Imports))
+    assert.Equal(t, &Import{Path: "common.proto", Public: true, Position: Position{Line: 8, Column: 1}}, file.Imports[1])
+
+    assert.Equal(t, 1, len(file.Messages))
+    user := file.Messages[0]
+    assert.Equal(t, Reserved{
+        Ranges: [][2]int{{4, 4}, {8, 10}, {100, math.MaxInt32}},
+        Names:  []string{"password"},
+    }, user.Reserved)
+    var fields []Field
+    for _, field := range user.Fields {
+        fields = append(fields, Field{Name: field.Name, Type: field.Type, Number: field.Number, Label: field.Label, Oneof: field.Oneof})
+    }
+    assert.Equal(t, []Field{
+        {Name: "name", Type: "string", Number: 1},
+        {Name: "tags", Type: "string", Number: 2, Label: "repeated"},
+        {Name: "scores", Type: "map<string, int64>", Number: 3},
+        {Name: "created_at", Type: ".google.protobuf.Timestamp", Number: 5},
+        {Name: "email", Type: "string", Number: 6, Oneof: "contact"},
+        {Name: "phone", Type: "string", Number: 7, Oneof: "contact"},
+        {Name: "status", Type: "Status", Number: 11},
+    }, fields)
+    assert.Equal(t, Position{Line: 19, Column: 5}, user.Fields[0].Position)
+
+    assert.Equal(t, 1, len(user.Enums))
+    assert.Equal(t, 3, len(user.Enums[0].Values))
+    assert.Equal(t, -1, user.Enums[0].Values[2].Number)
+    assert.Equal(t, true, user.Enums[0].Reserved.HasNumber(2))
+    assert.Equal(t, "Address", user.Messages[0].Name)
+
+    assert.Equal(t, 1, len(file.Services))
+    methods := file.Services[0].Methods
+    assert.Equal(t, 2, len(methods))
+    assert.Equal(t, &Method{Name: "GetUser", Input: "GetUserRequest", Output: "User", Position: Position{Line: 41, Column: 5}}, methods[0])
+    assert.Equal(t, true, methods[1].ClientStreaming && methods[1].ServerStreaming)
+}
+
+func TestParse_Error(t *testing.T) {
+    tests := []struct {
+        src  string
+        want string
+    }{
+        {"syntax = \"proto3\"", "a.proto:1:18: expected \";\", found EOF"},
+        {"message A {\n  string name = ;\n}", "a.proto:2:17: expected number, found \";\""},
+        {"message A {", "a.proto:1:12: expected \"}\", found EOF"},
+        {"/* comment", "a.proto:1:1: unterminated comment"},
+        {"foo;", "a.proto:1:1: unexpected \"foo\""},
+    }
+    for _, tt := range tests {
+        _, err := Parse("a.proto", tt.src)
+        assert.Equal(t, tt.want, err.Error())
+    }
+}
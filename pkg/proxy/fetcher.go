@@ -29,6 +29,7 @@ import (
     "github.com/json-iterator/go"
     "github.com/storyicon/gos/pkg/proxy/module"
     "github.com/storyicon/gos/pkg/util"
+    "golang.org/x/sync/singleflight"
 )
 
 // Fetcher defined the interface of GORPOXY api
@@ -45,6 +46,9 @@ type localFetcher struct {
     GoPath string
     Env    []string
     Config
+    // flight merges the concurrent calls for the same module,
+    // so that a module requested by several go processes is fetched once
+    flight singleflight.Group
 }
 
 func newLocalFetcher(c Config) (*localFetcher, error) {
@@ -60,6 +64,16 @@ func newLocalFetcher(c Config) (*localFetcher, error) {
 }
 
 func (c *localFetcher) list(mod *module.Module) (*module.List, error) {
+    value, err, _ := c.flight.Do("list "+mod.GetAddr(), func() (interface{}, error) {
+        return c.runList(mod)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return value.(*module.List), nil
+}
+
+func (c *localFetcher) runList(mod *module.Module) (*module.List, error) {
     fd := c.executeGo("list", []string{
         "-m", "-versions", "-json", mod.GetAddr(),
     })
@@ -158,6 +172,13 @@ func (c *localFetcher) prepareEnv() {
 }
 
 func (c *localFetcher) fetch(mod *module.Module) error {
+    _, err, _ := c.flight.Do("download "+mod.GetAddrWithVersion(), func() (interface{}, error) {
+        return nil, c.download(mod)
+    })
+    return err
+}
+
+func (c *localFetcher) download(mod *module.Module) error {
     fd := c.executeGo("mod", []string{
         "download", mod.GetAddrWithVersion(),
     })
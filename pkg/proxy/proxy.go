@@ -18,10 +18,13 @@ package proxy
 
 import (
     "io/ioutil"
+    "net"
+    "net/http"
     "sync"
 
     "github.com/gin-gonic/gin"
     "github.com/sirupsen/logrus"
+    "github.com/storyicon/gos/pkg/daemon"
     "github.com/storyicon/gos/pkg/meta"
     "github.com/storyicon/gos/pkg/proxy/module"
 )
@@ -88,6 +91,44 @@ func (engine *Engine) Run() error {
     return engine.s.Run(engine.ListenAddr)
 }
 
+// Serve is used to serve the proxy on the listener, it blocks until the listener is closed
+func (engine *Engine) Serve(ln net.Listener) error {
+    logrus.Debugln("local proxy run on:", ln.Addr())
+    return http.Serve(ln, engine.s)
+}
+
+// ServeHTTP implements http.Handler, so that the proxy can be served by the gos daemon
+func (engine *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    engine.s.ServeHTTP(w, r)
+}
+
+// Start is used to get the address of the shared gos daemon, which is started if it is not running.
+// If the daemon is disabled or unavailable, the default proxy is started in the background on the listen address,
+// the address is bound before it returns and the listener is handed over to the proxy directly,
+// so the connections made after Start are queued until they are served instead of being refused
+func Start(addr string) (string, error) {
+    if daemon.Enabled() {
+        state, err := daemon.Connect(daemon.DefaultKey(), daemon.StartTimeout)
+        if err == nil {
+            logrus.Debugln("local proxy is the gos daemon on:", state.Addr)
+            // the address is used by the go commands of the process until it exits
+            state.Hold()
+            return state.Addr, nil
+        }
+        logrus.Debugln("failed to connect to the gos daemon:", err)
+    }
+    engine := Default()
+    if addr == "" {
+        addr = engine.ListenAddr
+    }
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+        return "", err
+    }
+    go engine.Serve(ln)
+    return ln.Addr().String(), nil
+}
+
 // Interceptor intercepts all requests to process the GOPROXY part
 func (engine *Engine) Interceptor() gin.HandlerFunc {
     return func(c *gin.Context) {
@@ -136,6 +177,7 @@ func (engine *Engine) allocateContext() *Context {
 }
 
 func init() {
+    meta.RegisterProxyStarter(Start)
     gin.SetMode(gin.ReleaseMode)
     gin.DefaultWriter = ioutil.Discard
 }
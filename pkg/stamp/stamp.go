@@ -0,0 +1,197 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stamp
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/storyicon/gos/pkg/vcs"
+)
+
+// Flag is the gos flag to enable version stamping
+const Flag = "--stamp"
+
+// EnvSourceDateEpoch is used to make the build date reproducible
+const EnvSourceDateEpoch = "SOURCE_DATE_EPOCH"
+
+// ErrInvalidVariables is returned when the value of --stamp cannot be parsed
+var ErrInvalidVariables = errors.New("invalid --stamp, expected version=pkg.Var,commit=pkg.Var,date=pkg.Var,platform=pkg.Var")
+
+// Variables defines the package variables that will be set by -X,
+// an empty variable will not be set
+type Variables struct {
+    Version  string
+    Commit   string
+    Date     string
+    Platform string
+}
+
+// DefaultVariables is used when --stamp is specified without value
+var DefaultVariables = Variables{
+    Version:  "main.version",
+    Commit:   "main.commit",
+    Date:     "main.date",
+    Platform: "main.platform",
+}
+
+// ParseVariables is used to parse the value of --stamp,
+// such as "version=main.Version,commit=main.Commit",
+// the variables not specified keep their default values
+func ParseVariables(value string) (*Variables, error) {
+    variables := DefaultVariables
+    if value == "" {
+        return &variables, nil
+    }
+    for _, pair := range strings.Split(value, ",") {
+        i := strings.Index(pair, "=")
+        if i == -1 {
+            return nil, ErrInvalidVariables
+        }
+        name := pair[i+1:]
+        switch pair[:i] {
+        case "version":
+            variables.Version = name
+        case "commit":
+            variables.Commit = name
+        case "date":
+            variables.Date = name
+        case "platform":
+            variables.Platform = name
+        default:
+            return nil, ErrInvalidVariables
+        }
+    }
+    return &variables, nil
+}
+
+// Info defines the version metadata derived from the VCS state
+type Info struct {
+    Version string
+    Commit  string
+    Date    string
+}
+
+// Resolve is used to get the version metadata of the repository in dir,
+// unknown is used when the repository is not managed by git
+func Resolve(dir string) *Info {
+    info := &Info{
+        Version: "unknown",
+        Commit:  "unknown",
+        Date:    buildDate().UTC().Format(time.RFC3339),
+    }
+    if version, err := vcs.Describe(dir); err == nil {
+        info.Version = version
+    }
+    if commit, err := vcs.Commit(dir); err == nil {
+        info.Commit = commit
+    }
+    return info
+}
+
+func buildDate() time.Time {
+    if epoch := os.Getenv(EnvSourceDateEpoch); epoch != "" {
+        if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+            return time.Unix(sec, 0)
+        }
+    }
+    return time.Now()
+}
+
+// Stamp is used to inject version metadata into binaries
+type Stamp struct {
+    Variables
+    Info
+}
+
+// New is used to initialize a Stamp with the VCS state of dir
+func New(variables *Variables, dir string) *Stamp {
+    return &Stamp{
+        Variables: *variables,
+        Info:      *Resolve(dir),
+    }
+}
+
+// LDFlags is used to get the -X flags of the platform such as linux/amd64
+func (s *Stamp) LDFlags(platform string) string {
+    var flags []string
+    for _, pair := range [][2]string{
+        {s.Variables.Version, s.Info.Version},
+        {s.Variables.Commit, s.Info.Commit},
+        {s.Variables.Date, s.Info.Date},
+        {s.Variables.Platform, platform},
+    } {
+        if pair[0] != "" {
+            flags = append(flags, fmt.Sprintf("-X %s=%s", pair[0], pair[1]))
+        }
+    }
+    return strings.Join(flags, " ")
+}
+
+// Apply is used to merge the -X flags into the -ldflags of go build args
+func (s *Stamp) Apply(args []string, platform string) []string {
+    return MergeLDFlags(args, s.LDFlags(platform))
+}
+
+// MergeLDFlags is used to append flags to the last -ldflags in args,
+// -ldflags will be prepended when there is none. args will not be modified.
+func MergeLDFlags(args []string, flags string) []string {
+    r := make([]string, len(args))
+    copy(r, args)
+    for i := len(r) - 1; i >= 0; i-- {
+        arg := r[i]
+        for _, name := range []string{"-ldflags", "--ldflags"} {
+            if strings.HasPrefix(arg, name+"=") {
+                r[i] = joinFlags(arg, flags)
+                return r
+            }
+            if arg == name && i+1 < len(r) {
+                r[i+1] = joinFlags(r[i+1], flags)
+                return r
+            }
+        }
+    }
+    return append([]string{"-ldflags=" + flags}, r...)
+}
+
+func joinFlags(current, flags string) string {
+    if strings.HasSuffix(current, "=") {
+        return current + flags
+    }
+    return current + " " + flags
+}
+
+// TakeFlag is used to remove --stamp or --stamp=... from args,
+// the returned variables are nil when the flag is absent
+func TakeFlag(args []string) ([]string, *Variables, error) {
+    var r []string
+    var variables *Variables
+    for _, arg := range args {
+        if arg != Flag && !strings.HasPrefix(arg, Flag+"=") {
+            r = append(r, arg)
+            continue
+        }
+        v, err := ParseVariables(strings.TrimPrefix(strings.TrimPrefix(arg, Flag), "="))
+        if err != nil {
+            return nil, nil, err
+        }
+        variables = v
+    }
+    return r, variables, nil
+}
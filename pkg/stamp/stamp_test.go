@@ -0,0 +1,90 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stamp
+
+import (
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestMergeLDFlags(t *testing.T) {
+    tests := []struct {
+        name  string
+        args  []string
+        flags string
+        want  []string
+    }{
+        {
+            name:  "test0",
+            args:  []string{"-o", "app", "main.go"},
+            flags: "-X main.version=v1",
+            want:  []string{"-ldflags=-X main.version=v1", "-o", "app", "main.go"},
+        },
+        {
+            name:  "test1",
+            args:  []string{"-ldflags=-s -w", "main.go"},
+            flags: "-X main.version=v1",
+            want:  []string{"-ldflags=-s -w -X main.version=v1", "main.go"},
+        },
+        {
+            name:  "test2",
+            args:  []string{"-ldflags", "-s", "-a", "--ldflags", "-w", "main.go"},
+            flags: "-X main.version=v1",
+            want:  []string{"-ldflags", "-s", "-a", "--ldflags", "-w -X main.version=v1", "main.go"},
+        },
+        {
+            name:  "test3",
+            args:  []string{"-ldflags=", "main.go"},
+            flags: "-X main.version=v1",
+            want:  []string{"-ldflags=-X main.version=v1", "main.go"},
+        },
+    }
+    for _, tt := range tests {
+        assert.Equal(t, tt.want, MergeLDFlags(tt.args, tt.flags), tt.name)
+    }
+}
+
+func TestStamp_LDFlags(t *testing.T) {
+    variables, err := ParseVariables("version=github.com/a/b/build.Version,date=")
+    assert.Equal(t, nil, err)
+    s := &Stamp{
+        Variables: *variables,
+        Info: Info{
+            Version: "v1.2.0-3-gabcdef",
+            Commit:  "abcdef",
+            Date:    "2019-08-01T00:00:00Z",
+        },
+    }
+    assert.Equal(t,
+        "-X github.com/a/b/build.Version=v1.2.0-3-gabcdef -X main.commit=abcdef -X main.platform=linux/arm64",
+        s.LDFlags("linux/arm64"),
+    )
+
+    _, err = ParseVariables("release=main.release")
+    assert.Equal(t, ErrInvalidVariables, err)
+}
+
+func TestTakeFlag(t *testing.T) {
+    args, variables, err := TakeFlag([]string{"-v", "--stamp", "./..."})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"-v", "./..."}, args)
+    assert.Equal(t, &DefaultVariables, variables)
+
+    args, variables, err = TakeFlag([]string{"-v", "./..."})
+    assert.Equal(t, nil, err)
+    assert.Equal(t, []string{"-v", "./..."}, args)
+    assert.Equal(t, (*Variables)(nil), variables)
+}
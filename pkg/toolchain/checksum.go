@@ -0,0 +1,250 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolchain
+
+import (
+    "archive/zip"
+    "bufio"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+
+    "golang.org/x/mod/sumdb"
+)
+
+// DefaultSumDB is the checksum database used when GOSUMDB is not set
+const DefaultSumDB = "sum.golang.org"
+
+// knownSumDBs defines the verifier keys and urls of the checksum databases
+// which can be specified by name in GOSUMDB, the same as cmd/go
+var knownSumDBs = map[string][2]string{
+    "sum.golang.org":       {"sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8", "https://sum.golang.org"},
+    "sum.golang.google.cn": {"sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8", "https://sum.golang.google.cn"},
+}
+
+var (
+    // ErrChecksumMismatch is returned when the hash of the downloaded toolchain is not the recorded one
+    ErrChecksumMismatch = errors.New("checksum mismatch, the toolchain downloaded from the proxy is not the one in the checksum database")
+    // ErrNoChecksum is returned when the checksum of the toolchain is neither in go.sum nor in the checksum database
+    ErrNoChecksum = errors.New("no checksum of the toolchain is found in go.sum or the checksum database (GOSUMDB)")
+    // ErrUnknownSumDB is returned when GOSUMDB is a name without a verifier key which is not known
+    ErrUnknownSumDB = errors.New("unknown checksum database, GOSUMDB should be in the form of name+key [url]")
+)
+
+// HashZip is used to compute the h1: hash of the module zip, which is the hash recorded in go.sum
+// and the checksum database
+func HashZip(r io.ReaderAt, size int64) (string, error) {
+    reader, err := zip.NewReader(r, size)
+    if err != nil {
+        return "", err
+    }
+    files := make([]*zip.File, len(reader.File))
+    copy(files, reader.File)
+    sort.Slice(files, func(i, j int) bool {
+        return files[i].Name < files[j].Name
+    })
+    summary := sha256.New()
+    for _, file := range files {
+        if strings.Contains(file.Name, "\n") {
+            return "", fmt.Errorf("invalid file in zip: %q", file.Name)
+        }
+        h := sha256.New()
+        src, err := file.Open()
+        if err != nil {
+            return "", err
+        }
+        _, err = io.Copy(h, src)
+        src.Close()
+        if err != nil {
+            return "", err
+        }
+        fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), file.Name)
+    }
+    return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// Checksum is used to get the recorded h1: hash of the toolchain module version,
+// it is read from the go.sum of the current module, or requested from the checksum database directly
+// rather than through the GOPROXY which serves the zip. The signed tree of the checksum database
+// is verified with the key of GOSUMDB, and the record is verified to be in the tree
+func Checksum(modVersion string) (string, error) {
+    if hash := goSumHash(".", modVersion); hash != "" {
+        return hash, nil
+    }
+    ops, err := newSumDBOps()
+    if err != nil {
+        return "", err
+    }
+    if ops == nil {
+        return "", ErrNoChecksum
+    }
+    lines, err := sumdb.NewClient(ops).Lookup(ModulePath, modVersion)
+    if err != nil {
+        return "", err
+    }
+    hash, err := parseSum(strings.NewReader(strings.Join(lines, "\n")), modVersion)
+    if err == nil && hash == "" {
+        err = ErrNoChecksum
+    }
+    return hash, err
+}
+
+// sumDBOps implements the operations of the checksum database client,
+// the latest signed tree and the tiles are only kept in memory
+type sumDBOps struct {
+    key string
+    url string
+
+    mu     sync.Mutex
+    config map[string][]byte
+}
+
+// newSumDBOps is used to get the checksum database configured by GOSUMDB,
+// which is in the form of "name[+key] [url]", it returns nil if GOSUMDB is off
+func newSumDBOps() (*sumDBOps, error) {
+    value := strings.TrimSpace(os.Getenv("GOSUMDB"))
+    if value == "" {
+        value = DefaultSumDB
+    }
+    if value == "off" {
+        return nil, nil
+    }
+    fields := strings.Fields(value)
+    ops := &sumDBOps{key: fields[0], config: map[string][]byte{}}
+    switch known, ok := knownSumDBs[fields[0]]; {
+    case ok:
+        ops.key, ops.url = known[0], known[1]
+    case strings.Contains(fields[0], "+"):
+        ops.url = "https://" + strings.SplitN(fields[0], "+", 2)[0]
+    default:
+        return nil, fmt.Errorf("%s: %s", ErrUnknownSumDB, value)
+    }
+    if len(fields) > 1 {
+        ops.url = fields[1]
+    }
+    ops.url = strings.TrimRight(ops.url, "/")
+    return ops, nil
+}
+
+// ReadRemote is used to request the path from the checksum database
+func (o *sumDBOps) ReadRemote(path string) ([]byte, error) {
+    resp, err := http.Get(o.url + path)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("GET %s%s: %s", o.url, path, resp.Status)
+    }
+    return ioutil.ReadAll(resp.Body)
+}
+
+// ReadConfig is used to read the verifier key and the latest signed tree, which is empty at first
+func (o *sumDBOps) ReadConfig(file string) ([]byte, error) {
+    if file == "key" {
+        return []byte(o.key), nil
+    }
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    return o.config[file], nil
+}
+
+// WriteConfig is used to replace the latest signed tree
+func (o *sumDBOps) WriteConfig(file string, old, new []byte) error {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    if string(o.config[file]) != string(old) {
+        return sumdb.ErrWriteConflict
+    }
+    o.config[file] = new
+    return nil
+}
+
+// ReadCache always misses, the records and tiles are requested from the checksum database
+func (o *sumDBOps) ReadCache(file string) ([]byte, error) {
+    return nil, os.ErrNotExist
+}
+
+// WriteCache discards the records and tiles
+func (o *sumDBOps) WriteCache(file string, data []byte) {}
+
+// Log discards the messages of the client
+func (o *sumDBOps) Log(msg string) {}
+
+// SecurityError is used to print the security error, the client returns sumdb.ErrSecurity
+func (o *sumDBOps) SecurityError(msg string) {
+    fmt.Fprintf(os.Stderr, "gos: %s\n", msg)
+}
+
+// verify is used to check the downloaded zip against the recorded checksum
+func verify(r io.ReaderAt, size int64, modVersion string) error {
+    want, err := Checksum(modVersion)
+    if err != nil {
+        return err
+    }
+    got, err := HashZip(r, size)
+    if err != nil {
+        return err
+    }
+    if got != want {
+        return fmt.Errorf("%s\n\tdownloaded: %s\n\trecorded:   %s", ErrChecksumMismatch, got, want)
+    }
+    return nil
+}
+
+// goSumHash is used to find the hash of the toolchain module version in the go.sum
+// of the module containing dir, it returns an empty string if it is not recorded
+func goSumHash(dir string, modVersion string) string {
+    dir, err := filepath.Abs(dir)
+    if err != nil {
+        return ""
+    }
+    for {
+        if data, err := ioutil.ReadFile(filepath.Join(dir, "go.sum")); err == nil {
+            hash, _ := parseSum(strings.NewReader(string(data)), modVersion)
+            return hash
+        }
+        if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+            return ""
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir {
+            return ""
+        }
+        dir = parent
+    }
+}
+
+// parseSum is used to find the hash of the toolchain module version in the go.sum lines,
+// such as golang.org/toolchain v0.0.1-go1.21.0.linux-amd64 h1:...
+func parseSum(r io.Reader, modVersion string) (string, error) {
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        fields := strings.Fields(scanner.Text())
+        if len(fields) == 3 && fields[0] == ModulePath && fields[1] == modVersion {
+            return fields[2], nil
+        }
+    }
+    return "", scanner.Err()
+}
@@ -0,0 +1,455 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package toolchain manages the go toolchains downloaded as golang.org/toolchain modules
+// and the toolchain pinned by a project
+package toolchain
+
+import (
+    "archive/zip"
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "runtime"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/storyicon/gos/pkg/meta"
+)
+
+// ModulePath is the module which the go toolchains are released as
+const ModulePath = "golang.org/toolchain"
+
+// PinFile is the file which pins the go toolchain of the folder and its sub folders
+const PinFile = ".go-version"
+
+// Local is the version which selects the go binary in $PATH
+const Local = "local"
+
+var (
+    // ErrInvalidVersion is returned when the version is not a go version such as 1.21.0 or go1.21.0
+    ErrInvalidVersion = errors.New("invalid go version, expected a version such as 1.21.0 or go1.21.0")
+    // ErrNotFound is returned when the proxy has no toolchain of the version for the platform
+    ErrNotFound = errors.New("toolchain not found")
+)
+
+// Root is the folder which the toolchains are installed in, one sub folder per version
+var Root = defaultRoot()
+
+func defaultRoot() string {
+    if dir, err := os.UserCacheDir(); err == nil {
+        return filepath.Join(dir, "gos", "toolchains")
+    }
+    return filepath.Join(os.TempDir(), ".gos", "toolchains")
+}
+
+// Normalize is used to convert the version into the name of go release, such as 1.21.0 into go1.21.0
+func Normalize(version string) (string, error) {
+    version = strings.TrimSpace(version)
+    if !strings.HasPrefix(version, "go") {
+        version = "go" + version
+    }
+    if numbers, _ := parse(version); len(numbers) == 0 {
+        return "", ErrInvalidVersion
+    }
+    return version, nil
+}
+
+// parse is used to split the go version into its numbers and pre-release suffix, such as go1.21rc2 into [1 21] and rc2
+func parse(version string) ([]int, string) {
+    version = strings.TrimPrefix(version, "go")
+    i := strings.IndexFunc(version, func(r rune) bool {
+        return (r < '0' || r > '9') && r != '.'
+    })
+    suffix := ""
+    if i != -1 {
+        version, suffix = version[:i], version[i:]
+    }
+    var numbers []int
+    for _, part := range strings.Split(version, ".") {
+        n, err := strconv.Atoi(part)
+        if err != nil {
+            return nil, ""
+        }
+        numbers = append(numbers, n)
+    }
+    return numbers, suffix
+}
+
+// Compare is used to compare the go versions, it returns -1, 0 or 1,
+// the pre-releases such as go1.21rc2 are less than the release
+func Compare(a, b string) int {
+    an, as := parse(a)
+    bn, bs := parse(b)
+    for i := 0; i < len(an) || i < len(bn); i++ {
+        var x, y int
+        if i < len(an) {
+            x = an[i]
+        }
+        if i < len(bn) {
+            y = bn[i]
+        }
+        if x != y {
+            if x < y {
+                return -1
+            }
+            return 1
+        }
+    }
+    switch {
+    case as == bs:
+        return 0
+    case as == "":
+        return 1
+    case bs == "":
+        return -1
+    case as < bs:
+        return -1
+    }
+    return 1
+}
+
+// ModuleVersion is used to get the version of golang.org/toolchain for the go version and platform
+func ModuleVersion(version, goos, goarch string) string {
+    return fmt.Sprintf("v0.0.1-%s.%s-%s", version, goos, goarch)
+}
+
+// Dir is used to get the folder of the installed toolchain
+func Dir(version string) string {
+    return filepath.Join(Root, version)
+}
+
+// Binary is used to get the go binary of the installed toolchain
+func Binary(version string) string {
+    name := "go"
+    if runtime.GOOS == "windows" {
+        name += ".exe"
+    }
+    return filepath.Join(Dir(version), "bin", name)
+}
+
+// IsInstalled is used to determine whether the toolchain is installed
+func IsInstalled(version string) bool {
+    info, err := os.Stat(Binary(version))
+    return err == nil && !info.IsDir()
+}
+
+// Lookup is used to find the installed toolchain of the version,
+// a version without the patch number such as go1.22 matches the latest installed release of it
+func Lookup(version string) (string, bool) {
+    if IsInstalled(version) {
+        return version, true
+    }
+    versions, _ := Installed()
+    for i := len(versions) - 1; i >= 0; i-- {
+        if _, suffix := parse(versions[i]); suffix == "" && strings.HasPrefix(versions[i], version+".") {
+            return versions[i], true
+        }
+    }
+    return "", false
+}
+
+// Installed is used to list the installed toolchains in version order
+func Installed() ([]string, error) {
+    infos, err := ioutil.ReadDir(Root)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var versions []string
+    for _, info := range infos {
+        if info.IsDir() && strings.HasPrefix(info.Name(), "go") && IsInstalled(info.Name()) {
+            versions = append(versions, info.Name())
+        }
+    }
+    sortVersions(versions)
+    return versions, nil
+}
+
+func sortVersions(versions []string) {
+    sort.Slice(versions, func(i, j int) bool {
+        return Compare(versions[i], versions[j]) < 0
+    })
+}
+
+// ListRemote is used to list the toolchains of the current platform served by the GOPROXY in version order
+func ListRemote(proxy string) ([]string, error) {
+    resp, err := get(proxy, "list")
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    prefix := "v0.0.1-"
+    suffix := fmt.Sprintf(".%s-%s", runtime.GOOS, runtime.GOARCH)
+    var versions []string
+    scanner := bufio.NewScanner(resp.Body)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if strings.HasPrefix(line, prefix) && strings.HasSuffix(line, suffix) {
+            versions = append(versions, strings.TrimSuffix(strings.TrimPrefix(line, prefix), suffix))
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    sortVersions(versions)
+    return versions, nil
+}
+
+// Resolve is used to get the full version of the toolchain served by the GOPROXY,
+// a version without the patch number such as go1.22 is resolved to the latest release of it
+func Resolve(proxy string, version string) (string, error) {
+    versions, err := ListRemote(proxy)
+    if err != nil {
+        return "", err
+    }
+    latest := ""
+    for _, v := range versions {
+        if v == version {
+            return v, nil
+        }
+        if _, suffix := parse(v); suffix == "" && strings.HasPrefix(v, version+".") {
+            latest = v
+        }
+    }
+    if latest == "" {
+        return "", fmt.Errorf("%s %s-%s: %s", version, runtime.GOOS, runtime.GOARCH, ErrNotFound)
+    }
+    return latest, nil
+}
+
+// Install is used to download the toolchain from the GOPROXY and extract it into Root,
+// the zip is verified against go.sum or the checksum database before it is extracted
+func Install(proxy string, version string) error {
+    modVersion := ModuleVersion(version, runtime.GOOS, runtime.GOARCH)
+    resp, err := get(proxy, modVersion+".zip")
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if err := os.MkdirAll(Root, os.ModePerm); err != nil {
+        return err
+    }
+    archive, err := ioutil.TempFile(Root, ".download-")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(archive.Name())
+    defer archive.Close()
+    size, err := io.Copy(archive, resp.Body)
+    if err != nil {
+        return err
+    }
+    if err := verify(archive, size, modVersion); err != nil {
+        return fmt.Errorf("%s: %s", version, err)
+    }
+
+    tmp, err := ioutil.TempDir(Root, ".extract-")
+    if err != nil {
+        return err
+    }
+    defer os.RemoveAll(tmp)
+    prefix := ModulePath + "@" + modVersion + "/"
+    if err := extract(archive, size, prefix, tmp); err != nil {
+        return fmt.Errorf("%s: %s", version, err)
+    }
+    if err := os.RemoveAll(Dir(version)); err != nil {
+        return err
+    }
+    return os.Rename(tmp, Dir(version))
+}
+
+// extract is used to extract the files under the prefix of the module zip into dir.
+// The module zip does not record the file modes, the files in bin and pkg/tool are made executable
+func extract(r io.ReaderAt, size int64, prefix string, dir string) error {
+    reader, err := zip.NewReader(r, size)
+    if err != nil {
+        return err
+    }
+    for _, file := range reader.File {
+        if !strings.HasPrefix(file.Name, prefix) || strings.HasSuffix(file.Name, "/") {
+            continue
+        }
+        name := filepath.FromSlash(strings.TrimPrefix(file.Name, prefix))
+        if name == "" || filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+            return fmt.Errorf("invalid file in zip: %s", file.Name)
+        }
+        mode := os.FileMode(0644)
+        if slash := filepath.ToSlash(name); strings.HasPrefix(slash, "bin/") || strings.HasPrefix(slash, "pkg/tool/") {
+            mode = 0755
+        }
+        if err := extractFile(file, filepath.Join(dir, name), mode); err != nil {
+            return err
+        }
+    }
+    if _, err := os.Stat(filepath.Join(dir, "bin")); err != nil {
+        return errors.New("no go binary in zip")
+    }
+    return nil
+}
+
+func extractFile(file *zip.File, path string, mode os.FileMode) error {
+    if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+        return err
+    }
+    src, err := file.Open()
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+    dst, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(dst, src); err != nil {
+        dst.Close()
+        return err
+    }
+    return dst.Close()
+}
+
+// get is used to request the file of golang.org/toolchain from the GOPROXY
+func get(proxy string, file string) (*http.Response, error) {
+    url := strings.TrimRight(proxy, "/") + "/" + ModulePath + "/@v/" + file
+    resp, err := http.Get(url)
+    if err != nil {
+        return nil, err
+    }
+    if resp.StatusCode != http.StatusOK {
+        resp.Body.Close()
+        if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+            return nil, fmt.Errorf("%s: %s", file, ErrNotFound)
+        }
+        return nil, fmt.Errorf("%s: %s", url, resp.Status)
+    }
+    return resp, nil
+}
+
+// FindPin is used to find the pin file in the folder or its parent folders,
+// it returns the pinned version and the path of the pin file
+func FindPin(dir string) (string, string, error) {
+    dir, err := filepath.Abs(dir)
+    if err != nil {
+        return "", "", err
+    }
+    for {
+        path := filepath.Join(dir, PinFile)
+        data, err := ioutil.ReadFile(path)
+        if err == nil {
+            version, err := Normalize(firstLine(string(data)))
+            if err != nil {
+                return "", "", fmt.Errorf("%s: %s", path, err)
+            }
+            return version, path, nil
+        }
+        if !os.IsNotExist(err) {
+            return "", "", err
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir {
+            return "", "", nil
+        }
+        dir = parent
+    }
+}
+
+func firstLine(s string) string {
+    for _, line := range strings.Split(s, "\n") {
+        if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+            return line
+        }
+    }
+    return ""
+}
+
+// WritePin is used to pin the toolchain of the folder
+func WritePin(dir string, version string) error {
+    return ioutil.WriteFile(filepath.Join(dir, PinFile), []byte(version+"\n"), 0644)
+}
+
+// Selected is used to get the toolchain selected by GOS_TOOLCHAIN or the pin file of the current folder,
+// it returns the version and where it is selected, the version is empty if the go binary in $PATH is used
+func Selected() (string, string, error) {
+    if env := os.Getenv(meta.EnvGosToolchain); env != "" {
+        if env == Local {
+            return "", "", nil
+        }
+        version, err := Normalize(env)
+        return version, meta.EnvGosToolchain, err
+    }
+    return FindPin(".")
+}
+
+// Apply is used to make gos use the selected toolchain, it is installed through the proxy if needed
+func Apply(proxy func() (string, error)) error {
+    version, source, err := Selected()
+    if err != nil || version == "" {
+        return err
+    }
+    installed, ok := Lookup(version)
+    if !ok {
+        url, err := proxy()
+        if err != nil {
+            return err
+        }
+        if installed, err = Resolve(url, version); err != nil {
+            return err
+        }
+        fmt.Fprintf(os.Stderr, "gos: installing %s selected by %s\n", installed, source)
+        if err := Install(url, installed); err != nil {
+            return err
+        }
+    }
+    meta.SetGoBinaryPath(Binary(installed))
+    return nil
+}
+
+// Find is used to get the go binary of the toolchain specified by a version, a name or a path:
+// "local" is the go binary in $PATH, a path is a go binary or a GOROOT folder,
+// a name such as tip-local is a folder in Root, and a version is resolved by Lookup
+func Find(spec string) (string, error) {
+    if spec == Local {
+        return exec.LookPath("go")
+    }
+    if strings.ContainsAny(spec, `/\`) {
+        info, err := os.Stat(spec)
+        if err != nil {
+            return "", err
+        }
+        if !info.IsDir() {
+            return filepath.Abs(spec)
+        }
+        return filepath.Abs(filepath.Join(spec, "bin", filepath.Base(Binary(""))))
+    }
+    if IsInstalled(spec) {
+        return Binary(spec), nil
+    }
+    version, err := Normalize(spec)
+    if err != nil {
+        return "", fmt.Errorf("%s: %s", spec, err)
+    }
+    if installed, ok := Lookup(version); ok {
+        return Binary(installed), nil
+    }
+    return "", fmt.Errorf("%s is not installed, install it by gos toolchain install %s", version, spec)
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolchain
+
+import (
+    "archive/zip"
+    "bytes"
+    "crypto/rand"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+    "golang.org/x/mod/sumdb"
+    "golang.org/x/mod/sumdb/note"
+)
+
+func TestCompare(t *testing.T) {
+    versions := []string{"go1.22.0", "go1.9", "go1.21rc2", "go1.21.10", "go1.21.2", "go1.21.0"}
+    sortVersions(versions)
+    assert.Equal(t, []string{"go1.9", "go1.21rc2", "go1.21.0", "go1.21.2", "go1.21.10", "go1.22.0"}, versions)
+}
+
+func TestNormalize(t *testing.T) {
+    version, err := Normalize(" 1.21.0\n")
+    assert.NoError(t, err)
+    assert.Equal(t, "go1.21.0", version)
+    _, err = Normalize("latest")
+    assert.Equal(t, ErrInvalidVersion, err)
+}
+
+func buildZip(t *testing.T, prefix string, files map[string]string) []byte {
+    buf := &bytes.Buffer{}
+    w := zip.NewWriter(buf)
+    for name, content := range files {
+        f, err := w.Create(prefix + name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        f.Write([]byte(content))
+    }
+    w.Close()
+    return buf.Bytes()
+}
+
+// standIn is a GOPROXY serving toolchains with a checksum database under /sumdb
+type standIn struct {
+    *httptest.Server
+    // SumDB is the value of GOSUMDB for the checksum database
+    SumDB string
+}
+
+// newStandIn serves the toolchains of the versions for the current platform like a GOPROXY,
+// and their hashes like the checksum database under /sumdb, the zip of the tampered version
+// is changed after it is hashed
+func newStandIn(t *testing.T, tampered string, versions ...string) *standIn {
+    mux := http.NewServeMux()
+    hashes := map[string]string{}
+    var list []string
+    for _, version := range versions {
+        modVersion := ModuleVersion(version, runtime.GOOS, runtime.GOARCH)
+        list = append(list, modVersion, ModuleVersion(version, "plan9", "386"))
+
+        files := map[string]string{
+            "bin/go":                 "#!/bin/sh\necho " + version,
+            "bin/go.exe":             version,
+            "pkg/tool/compile":       "compile",
+            "src/runtime/runtime.go": "package runtime",
+        }
+        data := buildZip(t, ModulePath+"@"+modVersion+"/", files)
+        hash, err := HashZip(bytes.NewReader(data), int64(len(data)))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if version == tampered {
+            files["bin/go"] = "#!/bin/sh\necho tampered"
+            data = buildZip(t, ModulePath+"@"+modVersion+"/", files)
+        }
+        hashes[modVersion] = hash
+        mux.HandleFunc("/"+ModulePath+"/@v/"+modVersion+".zip", func(w http.ResponseWriter, r *http.Request) {
+            w.Write(data)
+        })
+    }
+    mux.HandleFunc("/"+ModulePath+"/@v/list", func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte(strings.Join(list, "\n")))
+    })
+
+    skey, vkey, err := note.GenerateKey(rand.Reader, "sum.test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    db := sumdb.NewTestServer(skey, func(path, vers string) ([]byte, error) {
+        hash, ok := hashes[vers]
+        if path != ModulePath || !ok {
+            return nil, fmt.Errorf("%s@%s not found", path, vers)
+        }
+        return []byte(fmt.Sprintf("%s %s %s\n%s %s/go.mod h1:x\n", path, vers, hash, path, vers)), nil
+    })
+    mux.Handle("/sumdb/", http.StripPrefix("/sumdb", sumdb.NewServer(db)))
+    server := httptest.NewServer(mux)
+    return &standIn{Server: server, SumDB: vkey + " " + server.URL + "/sumdb"}
+}
+
+func TestInstall(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    Root = dir
+
+    server := newStandIn(t, "", "go1.21.0", "go1.22.0", "go1.22.3", "go1.23rc1")
+    defer server.Close()
+    defer os.Setenv("GOSUMDB", os.Getenv("GOSUMDB"))
+    os.Setenv("GOSUMDB", server.SumDB)
+
+    versions, err := ListRemote(server.URL)
+    assert.NoError(t, err)
+    assert.Equal(t, []string{"go1.21.0", "go1.22.0", "go1.22.3", "go1.23rc1"}, versions)
+
+    version, err := Resolve(server.URL, "go1.22")
+    assert.NoError(t, err)
+    assert.Equal(t, "go1.22.3", version)
+    _, err = Resolve(server.URL, "go1.23")
+    assert.Error(t, err)
+
+    assert.NoError(t, Install(server.URL, "go1.22.3"))
+    assert.True(t, IsInstalled("go1.22.3"))
+    if runtime.GOOS != "windows" {
+        info, err := os.Stat(Binary("go1.22.3"))
+        if assert.NoError(t, err) {
+            assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+        }
+    }
+    installed, err := Installed()
+    assert.NoError(t, err)
+    assert.Equal(t, []string{"go1.22.3"}, installed)
+    version, ok := Lookup("go1.22")
+    assert.True(t, ok)
+    assert.Equal(t, "go1.22.3", version)
+
+    err = Install(server.URL, "go1.20.0")
+    assert.Error(t, err)
+    assert.False(t, IsInstalled("go1.20.0"))
+}
+
+func TestInstall_ChecksumMismatch(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    Root = dir
+
+    server := newStandIn(t, "go1.21.0", "go1.21.0")
+    defer server.Close()
+    defer os.Setenv("GOSUMDB", os.Getenv("GOSUMDB"))
+
+    os.Setenv("GOSUMDB", server.SumDB)
+    err = Install(server.URL, "go1.21.0")
+    if assert.Error(t, err) {
+        assert.Contains(t, err.Error(), ErrChecksumMismatch.Error())
+    }
+    assert.False(t, IsInstalled("go1.21.0"))
+
+    os.Setenv("GOSUMDB", "off")
+    err = Install(server.URL, "go1.21.0")
+    if assert.Error(t, err) {
+        assert.Contains(t, err.Error(), ErrNoChecksum.Error())
+    }
+    assert.False(t, IsInstalled("go1.21.0"))
+}
+
+func TestChecksum_Verify(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    wd, _ := os.Getwd()
+    defer os.Chdir(wd)
+    os.Chdir(dir)
+
+    server := newStandIn(t, "", "go1.21.0")
+    defer server.Close()
+    defer os.Setenv("GOSUMDB", os.Getenv("GOSUMDB"))
+    modVersion := ModuleVersion("go1.21.0", runtime.GOOS, runtime.GOARCH)
+
+    os.Setenv("GOSUMDB", server.SumDB)
+    hash, err := Checksum(modVersion)
+    assert.NoError(t, err)
+    assert.True(t, strings.HasPrefix(hash, "h1:"))
+
+    // the signed tree is not accepted with the key of another database
+    _, vkey, err := note.GenerateKey(rand.Reader, "sum.test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    os.Setenv("GOSUMDB", vkey+" "+server.URL+"/sumdb")
+    _, err = Checksum(modVersion)
+    assert.Error(t, err)
+
+    // the key of a database can not be guessed from its name
+    os.Setenv("GOSUMDB", "sum.test "+server.URL+"/sumdb")
+    _, err = Checksum(modVersion)
+    if assert.Error(t, err) {
+        assert.Contains(t, err.Error(), ErrUnknownSumDB.Error())
+    }
+}
+
+func TestFindPin(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gos-toolchain")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    sub := filepath.Join(dir, "a", "b")
+    if err := os.MkdirAll(sub, os.ModePerm); err != nil {
+        t.Fatal(err)
+    }
+
+    version, _, err := FindPin(sub)
+    assert.NoError(t, err)
+    assert.Equal(t, "", version)
+
+    assert.NoError(t, WritePin(dir, "go1.21.0"))
+    version, path, err := FindPin(sub)
+    assert.NoError(t, err)
+    assert.Equal(t, "go1.21.0", version)
+    assert.Equal(t, filepath.Join(dir, PinFile), path)
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "os/signal"
+)
+
+// Here defines the exit codes of the failures to start a command, which are the same as shells
+const (
+    ExitCodeCannotExecute = 126
+    ExitCodeNotFound      = 127
+)
+
+// RunCMD is used to run the command as if it were run by the shell directly.
+// The standard input and outputs of the current process are used if they are not specified,
+// SIGINT, SIGTERM and SIGHUP received by gos are forwarded to the command.
+// It returns the exit code of the command, the error is returned when the command can not be started
+func RunCMD(fd *exec.Cmd) (int, error) {
+    if fd.Stdin == nil {
+        fd.Stdin = os.Stdin
+    }
+    if fd.Stdout == nil {
+        fd.Stdout = os.Stdout
+    }
+    if fd.Stderr == nil {
+        fd.Stderr = os.Stderr
+    }
+    foreground := fd.Stdin == os.Stdin && isTerminal(os.Stdin)
+    prepareCMD(fd, foreground)
+
+    signals := make(chan os.Signal, 4)
+    signal.Notify(signals, forwardedSignals...)
+    defer signal.Stop(signals)
+
+    if err := fd.Start(); err != nil {
+        if e, ok := err.(*exec.Error); (ok && e.Err == exec.ErrNotFound) || os.IsNotExist(err) {
+            return ExitCodeNotFound, err
+        }
+        return ExitCodeCannotExecute, err
+    }
+
+    done := make(chan struct{})
+    go func() {
+        for {
+            select {
+            case sig := <-signals:
+                forwardSignal(fd.Process, sig, foreground)
+            case <-done:
+                return
+            }
+        }
+    }()
+    err := fd.Wait()
+    close(done)
+    if err == nil {
+        return 0, nil
+    }
+    if exitError, ok := err.(*exec.ExitError); ok {
+        return getExitCode(exitError), nil
+    }
+    // the command exited, but copying its input or output failed
+    return 1, err
+}
+
+// RunCMDWithExit is used to run the command by RunCMD and exit with its exit code when it fails,
+// the failures to start the command are reported to stderr
+func RunCMDWithExit(fd *exec.Cmd) {
+    code, err := RunCMD(fd)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "gos: failed to run %s: %s\n", fd.Path, err)
+    }
+    if code != 0 {
+        os.Exit(code)
+    }
+}
+
+// isTerminal is used to determine whether the file is a terminal
+func isTerminal(file *os.File) bool {
+    info, err := file.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
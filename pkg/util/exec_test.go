@@ -0,0 +1,81 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+    "bytes"
+    "os/exec"
+    "strings"
+    "syscall"
+    "testing"
+    "time"
+)
+
+func TestRunCMD(t *testing.T) {
+    tests := []struct {
+        name    string
+        fd      *exec.Cmd
+        want    int
+        wantErr bool
+    }{
+        {name: "success", fd: exec.Command("sh", "-c", "exit 0"), want: 0},
+        {name: "exit code", fd: exec.Command("sh", "-c", "exit 3"), want: 3},
+        {name: "killed", fd: exec.Command("sh", "-c", "kill -9 $$"), want: 128 + 9},
+        {name: "not found", fd: exec.Command("gos-command-not-found"), want: ExitCodeNotFound, wantErr: true},
+    }
+    for _, tt := range tests {
+        tt.fd.Stdin = strings.NewReader("")
+        got, err := RunCMD(tt.fd)
+        if got != tt.want || (err != nil) != tt.wantErr {
+            t.Errorf("%q. RunCMD() = %v, %v, want %v", tt.name, got, err, tt.want)
+        }
+    }
+}
+
+func TestRunCMD_Stdin(t *testing.T) {
+    stdout := &bytes.Buffer{}
+    fd := exec.Command("sh", "-c", "read line; echo got $line")
+    fd.Stdin = strings.NewReader("hello\n")
+    fd.Stdout = stdout
+    if code, err := RunCMD(fd); code != 0 || err != nil {
+        t.Fatalf("RunCMD() = %v, %v", code, err)
+    }
+    if got := stdout.String(); got != "got hello\n" {
+        t.Errorf("RunCMD() output = %q", got)
+    }
+}
+
+func TestRunCMD_ForwardSignal(t *testing.T) {
+    // the child of the command is in the same process group and receives the signal too
+    fd := exec.Command("sh", "-c", `trap "exit 7" TERM; sleep 10 & wait`)
+    fd.Stdin = strings.NewReader("")
+    go func() {
+        time.Sleep(500 * time.Millisecond)
+        syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+    }()
+    start := time.Now()
+    code, err := RunCMD(fd)
+    if code != 7 || err != nil {
+        t.Errorf("RunCMD() = %v, %v, want 7", code, err)
+    }
+    if time.Since(start) > 5*time.Second {
+        t.Errorf("the signal is not forwarded in time")
+    }
+}
@@ -0,0 +1,60 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+    "os"
+    "os/exec"
+    "syscall"
+)
+
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+// prepareCMD puts the command into its own process group unless it runs in the foreground of terminal,
+// so that the signals can be forwarded to the command and all of its children, such as the binary of go run.
+// In the foreground, the command shares the process group of gos to read the terminal
+func prepareCMD(fd *exec.Cmd, foreground bool) {
+    if foreground {
+        return
+    }
+    if fd.SysProcAttr == nil {
+        fd.SysProcAttr = &syscall.SysProcAttr{}
+    }
+    fd.SysProcAttr.Setpgid = true
+}
+
+func forwardSignal(process *os.Process, sig os.Signal, foreground bool) {
+    if !foreground {
+        syscall.Kill(-process.Pid, sig.(syscall.Signal))
+        return
+    }
+    // the terminal has sent SIGINT to the whole foreground process group
+    if sig != syscall.SIGINT {
+        process.Signal(sig)
+    }
+}
+
+// getExitCode is used to get the exit code of command, which is 128 + signal if it is killed by a signal
+func getExitCode(err *exec.ExitError) int {
+    if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+        return 128 + int(status.Signal())
+    }
+    return err.ExitCode()
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2019 storyicon@foxmail.com
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+    "os"
+    "os/exec"
+)
+
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// prepareCMD does nothing on windows, the console sends Ctrl+C to all attached processes
+func prepareCMD(fd *exec.Cmd, foreground bool) {}
+
+func forwardSignal(process *os.Process, sig os.Signal, foreground bool) {}
+
+func getExitCode(err *exec.ExitError) int {
+    return err.ExitCode()
+}
@@ -17,13 +17,13 @@
 package util
 
 import (
-    "fmt"
     "net"
     "os"
     "os/exec"
 
     "strings"
 
+    log "github.com/sirupsen/logrus"
     "github.com/storyicon/gos/pkg/meta"
 )
 
@@ -38,11 +38,25 @@ func GetGoBinaryCMD(subcmd string, args []string) *exec.Cmd {
     return exec.Command(binary, Prepend(args, subcmd)...)
 }
 
-// GetEnvWithLocalProxy is used to get env with go proxy
+// GetEnvWithLocalProxy is used to get env with go proxy,
+// the local proxy is started on the first call, and the env is kept if it fails to start
 func GetEnvWithLocalProxy() []string {
-    proxy := meta.GetLocalProxyListenAddr()
+    proxy, err := GetLocalProxyURL()
+    if err != nil {
+        log.Warnf("failed to start the local proxy: %s", err)
+        return os.Environ()
+    }
+    return append(os.Environ(), "GOPROXY="+proxy)
+}
+
+// GetLocalProxyURL is used to get the url of the local proxy, it is started on the first call
+func GetLocalProxyURL() (string, error) {
+    proxy, err := meta.StartLocalProxy()
+    if err != nil {
+        return "", err
+    }
     _, port, _ := net.SplitHostPort(proxy)
-    return append(os.Environ(), "GOPROXY=http://127.0.0.1:"+port)
+    return "http://127.0.0.1:" + port, nil
 }
 
 // GetEnvWithoutGoProxy is used to get env without go proxy
@@ -67,14 +81,3 @@ func RestoreCMDArgs(args []string) []string {
     }
     return r
 }
-
-// RunCMDWithExit encapsulates the Run command of exec.CMD
-func RunCMDWithExit(fd *exec.Cmd) {
-    if err := fd.Run(); err != nil {
-        exitError, isOK := err.(*exec.ExitError)
-        if isOK {
-            fmt.Print(exitError)
-            os.Exit(exitError.ExitCode())
-        }
-    }
-}
@@ -0,0 +1,66 @@
+// Copyright 2019 storyicon@foxmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vcs
+
+import (
+    "bytes"
+    "errors"
+    "os/exec"
+    "strings"
+)
+
+// GitBinaryPath is the location of git binary
+var GitBinaryPath = "git"
+
+// Describe is used to get the output of `git describe --tags --always --dirty` in dir
+func Describe(dir string) (string, error) {
+    return runGit(dir, "describe", "--tags", "--always", "--dirty")
+}
+
+// Commit is used to get the full hash of HEAD in dir
+func Commit(dir string) (string, error) {
+    return runGit(dir, "rev-parse", "HEAD")
+}
+
+func runGit(dir string, args ...string) (string, error) {
+    fd := exec.Command(GitBinaryPath, args...)
+    stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+    fd.Dir = dir
+    fd.Stdout = stdout
+    fd.Stderr = stderr
+    if err := fd.Run(); err != nil {
+        if stderr.Len() != 0 {
+            return "", errors.New(strings.TrimSpace(stderr.String()))
+        }
+        return "", err
+    }
+    return strings.TrimSpace(stdout.String()), nil
+}
+
+// ShowFile is used to get the content of the file at the revision,
+// path is relative to dir
+func ShowFile(dir string, revision string, path string) (string, error) {
+    return runGit(dir, "show", revision+":./"+strings.TrimPrefix(path, "./"))
+}
+
+// ListFiles is used to get the files in dir at the revision, the paths are relative to dir
+func ListFiles(dir string, revision string) ([]string, error) {
+    output, err := runGit(dir, "ls-tree", "-r", "-z", "--name-only", revision, "--", ".")
+    output = strings.TrimRight(output, "\x00")
+    if err != nil || output == "" {
+        return nil, err
+    }
+    return strings.Split(output, "\x00"), nil
+}